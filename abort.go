@@ -0,0 +1,95 @@
+package p0d
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const abortCheckInterval = time.Second
+
+var abortForRegex = regexp.MustCompile(`^(.*?)\s+for\s+(\d+)s\s*$`)
+
+type AbortCondition struct {
+	Threshold
+	ForSeconds int
+}
+
+func parseAbortCondition(s string) (AbortCondition, error) {
+	//same syntax as thresholds, plus an optional 'for 10s' to require consecutive seconds.
+	a := AbortCondition{ForSeconds: 1}
+	expr := s
+	if m := abortForRegex.FindStringSubmatch(s); m != nil {
+		expr = m[1]
+		a.ForSeconds, _ = strconv.Atoi(m[2])
+		if a.ForSeconds < 1 {
+			return a, fmt.Errorf("abort condition '%s' needs at least 'for 1s'", s)
+		}
+	}
+	t, e := parseThreshold(expr)
+	if e != nil {
+		return a, e
+	}
+	a.Threshold = t
+	a.raw = s
+	return a, nil
+}
+
+func (cfg *Config) validateAbort() {
+	cfg.aborts = make([]AbortCondition, 0, len(cfg.Abort))
+	for _, s := range cfg.Abort {
+		a, e := parseAbortCondition(s)
+		if e != nil {
+			cfg.panic(e.Error())
+		}
+		cfg.aborts = append(cfg.aborts, a)
+	}
+}
+
+func (p *P0d) initAbortWindow(now time.Time) {
+	p.abortBreaches = make([]int, len(p.Config.aborts))
+	p.resetAbortWindow(now)
+}
+
+func (p *P0d) resetAbortWindow(now time.Time) {
+	//abort conditions look at the last second only, not at the whole run.
+	if p.abortWindow == nil {
+		p.abortWindow = NewReqStats(Config{Exec: p.Config.Exec})
+	} else {
+		p.abortWindow = p.abortWindow.renew()
+	}
+	p.abortWindow.setStart(now)
+}
+
+func (p *P0d) checkAbort(now time.Time) bool {
+	defer p.resetAbortWindow(now)
+	//a hanging target completes nothing, that's a latency breach, not a pass. rates are meaningful at zero,
+	//percentages aren't, and a slow target may not finish anything while it is still ramping up.
+	empty := p.abortWindow.ReqAtmpts == 0
+	for i, a := range p.Config.aborts {
+		if empty && a.metric.kind != rateKind {
+			if a.metric.kind != latencyKind || p.Time.Phase < Main {
+				p.abortBreaches[i] = 0
+				continue
+			}
+			p.abortBreaches[i]++
+			if p.abortBreaches[i] >= a.ForSeconds {
+				p.AbortReason = fmt.Sprintf("%s, no requests completed", a.raw)
+				return true
+			}
+			continue
+		}
+		v := a.actual(p.abortWindow)
+		if !a.holds(v) {
+			p.abortBreaches[i] = 0
+			continue
+		}
+		p.abortBreaches[i]++
+		if p.abortBreaches[i] >= a.ForSeconds {
+			p.AbortReason = fmt.Sprintf("%s, was %s", a.raw, a.format(v))
+			return true
+		}
+	}
+	return false
+}
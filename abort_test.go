@@ -0,0 +1,148 @@
+package p0d
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type abortTest struct {
+	n          string
+	s          string
+	metric     string
+	forSeconds int
+	err        bool
+}
+
+func TestParseAbortCondition(t *testing.T) {
+	tests := []abortTest{
+		{n: "with for", s: "errors > 20% for 10s", metric: "errors", forSeconds: 10},
+		{n: "without for", s: "p95 > 5s", metric: "p95", forSeconds: 1},
+		{n: "for zero", s: "p95 > 5s for 0s", err: true},
+		{n: "bad threshold", s: "p95 > 5 for 3s", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			a, e := parseAbortCondition(tt.s)
+			if tt.err {
+				if e == nil {
+					t.Error("should not parse")
+				}
+				return
+			}
+			if e != nil || a.Metric != tt.metric || a.ForSeconds != tt.forSeconds || a.raw != tt.s {
+				t.Errorf("want %v for %v got %v for %v %v", tt.metric, tt.forSeconds, a.Metric, a.ForSeconds, e)
+			}
+		})
+	}
+}
+
+func TestCheckAbort(t *testing.T) {
+	cfg := Config{Res: Res{Code: 200}, Abort: []string{"errors > 20% for 2s"}}
+	cfg.validateAbort()
+	p := P0d{Config: cfg}
+
+	now := time.Now()
+	p.initAbortWindow(now)
+	second := func(errs int) bool {
+		for i := 0; i < 10; i++ {
+			ra := ReqAtmpt{ResCode: 200}
+			if i < errs {
+				ra.ResErr = "timeout"
+			}
+			p.abortWindow.update(ra, now.Add(time.Millisecond*500), cfg)
+		}
+		now = now.Add(time.Second)
+		return p.checkAbort(now)
+	}
+
+	if second(5) {
+		t.Error("should not abort after one bad second")
+	}
+	if second(1) {
+		t.Error("should not abort after a good second")
+	}
+	if second(5) {
+		t.Error("should not abort, bad seconds weren't consecutive")
+	}
+	if !second(3) {
+		t.Error("should abort after two consecutive bad seconds")
+	}
+	if p.AbortReason != "errors > 20% for 2s, was 30.00%" {
+		t.Errorf("abort reason incorrect, got %v", p.AbortReason)
+	}
+	if p.ExitCode() != ExitAborted {
+		t.Errorf("want exit code %v got %v", ExitAborted, p.ExitCode())
+	}
+}
+
+func TestCheckAbortEmptyWindow(t *testing.T) {
+	cfg := Config{Res: Res{Code: 200}, Abort: []string{"p95 > 5s for 2s", "errors > 20% for 1s"}}
+	cfg.validateAbort()
+	p := P0d{Config: cfg, Time: Time{Phase: RampUp}}
+
+	now := time.Now()
+	p.initAbortWindow(now)
+	now = now.Add(time.Second)
+	if p.checkAbort(now) {
+		t.Error("should not abort on an empty second during ramp up")
+	}
+	p.Time.Phase = Main
+	now = now.Add(time.Second)
+	if p.checkAbort(now) {
+		t.Error("should not abort after one empty second")
+	}
+	now = now.Add(time.Second)
+	if !p.checkAbort(now) {
+		t.Error("should abort after two consecutive empty seconds")
+	}
+	if p.AbortReason != "p95 > 5s for 2s, no requests completed" {
+		t.Errorf("abort reason incorrect, got %v", p.AbortReason)
+	}
+}
+
+func TestRaceWithAbort(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	cfg := Config{
+		Req:   Req{Url: svr.URL},
+		Exec:  Exec{DurationSeconds: 20, Concurrency: 2, SkipInetTest: true},
+		Abort: []string{"matchingCodes < 50% for 2s"},
+	}
+	cfg.validate()
+	p := NewP0d(cfg, 1024, "", cfg.Exec.DurationSeconds, interruptChannel())
+	p.Race(context.Background())
+
+	if p.AbortReason == N {
+		t.Error("should record abort reason")
+	}
+	if p.Time.Stop.Sub(p.Time.Start) > time.Second*10 {
+		t.Error("should abort early")
+	}
+}
+
+func TestRaceWithAbortSlowTarget(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 1500)
+	}))
+	defer svr.Close()
+
+	//seconds without completed requests aren't errors, a healthy slow target runs to the end.
+	cfg := Config{
+		Req:   Req{Url: svr.URL},
+		Exec:  Exec{DurationSeconds: 4, Concurrency: 2, SkipInetTest: true},
+		Abort: []string{"errors > 20%"},
+	}
+	cfg.validate()
+	p := NewP0d(cfg, 1024, "", cfg.Exec.DurationSeconds, interruptChannel())
+	r, _ := p.Race(context.Background())
+
+	if p.AbortReason != N || r.ExitCode == ExitAborted {
+		t.Errorf("slow target should not abort, got %v", p.AbortReason)
+	}
+}
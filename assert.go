@@ -0,0 +1,171 @@
+package p0d
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Assertions struct {
+	Codes            []CodeRange
+	Headers          map[string]string
+	BodyContains     string
+	BodyRegex        string
+	JsonPath         []JsonPathAssertion
+	MaxBodyBytes     int64
+	MaxLatencyMillis int
+
+	codes     [][2]int
+	bodyRegex *regexp.Regexp
+}
+
+type JsonPathAssertion struct {
+	Path   string
+	Equals string
+}
+
+type CodeRange string
+
+func (c *CodeRange) UnmarshalJSON(b []byte) error {
+	//yaml numbers come through as json numbers, we want both 200 and "2xx"
+	var n int
+	if json.Unmarshal(b, &n) == nil {
+		*c = CodeRange(strconv.Itoa(n))
+		return nil
+	}
+	var s string
+	e := json.Unmarshal(b, &s)
+	*c = CodeRange(s)
+	return e
+}
+
+func (c CodeRange) parse() (int, int, error) {
+	//a status code like 200, a class like 2xx or a range like 200-299
+	s := strings.ToLower(strings.TrimSpace(string(c)))
+	if len(s) == 3 && strings.HasSuffix(s, "xx") {
+		d, e := strconv.Atoi(s[:1])
+		if e != nil || d < 1 || d > 5 {
+			return 0, 0, fmt.Errorf("bad status code class %s", c)
+		}
+		return d * 100, d*100 + 99, nil
+	}
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		l, e1 := strconv.Atoi(strings.TrimSpace(lo))
+		h, e2 := strconv.Atoi(strings.TrimSpace(hi))
+		if e1 != nil || e2 != nil || l > h {
+			return 0, 0, fmt.Errorf("bad status code range %s", c)
+		}
+		return l, h, nil
+	}
+	n, e := strconv.Atoi(s)
+	if e != nil {
+		return 0, 0, fmt.Errorf("bad status code %s", c)
+	}
+	return n, n, nil
+}
+
+const (
+	assertCode         = "code"
+	assertHeader       = "header %s"
+	assertBodyContains = "body contains"
+	assertBodyRegex    = "body regex"
+	assertJsonPath     = "jsonPath %s"
+	assertMaxBodyBytes = "max body bytes"
+	assertMaxLatency   = "max latency"
+)
+
+func (cfg *Config) validateAssertions() {
+	a := &cfg.Res.Assertions
+	a.codes = make([][2]int, 0, len(a.Codes))
+	for _, c := range a.Codes {
+		l, h, e := c.parse()
+		if e != nil {
+			cfg.panic(e.Error())
+		}
+		a.codes = append(a.codes, [2]int{l, h})
+	}
+	if a.BodyRegex != "" {
+		r, e := regexp.Compile(a.BodyRegex)
+		if e != nil {
+			cfg.panic(fmt.Sprintf("assertion has bad bodyRegex: %s", e.Error()))
+		}
+		a.bodyRegex = r
+	}
+	for _, j := range a.JsonPath {
+		if !strings.HasPrefix(j.Path, "$") {
+			cfg.panic(fmt.Sprintf("assertion jsonPath must start with $, yours: %s", j.Path))
+		}
+	}
+	if a.MaxBodyBytes < 0 || a.MaxLatencyMillis < 0 {
+		cfg.panic("assertion maximums cannot be negative")
+	}
+}
+
+func (a *Assertions) active() bool {
+	return len(a.codes) > 0 || len(a.Headers) > 0 || a.BodyContains != "" || a.bodyRegex != nil ||
+		len(a.JsonPath) > 0 || a.MaxBodyBytes > 0 || a.MaxLatencyMillis > 0
+}
+
+func (a *Assertions) needsBody() bool {
+	return a.BodyContains != "" || a.bodyRegex != nil || len(a.JsonPath) > 0 || a.MaxBodyBytes > 0
+}
+
+func (r *Res) matchesCode(code int) bool {
+	//assertion codes take over from res.code when present
+	if len(r.Assertions.codes) == 0 {
+		return code == r.Code
+	}
+	for _, c := range r.Assertions.codes {
+		if code >= c[0] && code <= c[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Res) assert(res *http.Response, elpsd time.Duration) []string {
+	a := &r.Assertions
+	var fails []string
+	if len(a.codes) > 0 && !r.matchesCode(res.StatusCode) {
+		fails = append(fails, assertCode)
+	}
+	for k, v := range a.Headers {
+		hv := res.Header.Values(k)
+		if len(hv) == 0 || (v != "" && !contains(hv, v)) {
+			fails = append(fails, fmt.Sprintf(assertHeader, k))
+		}
+	}
+	if a.MaxLatencyMillis > 0 && elpsd > time.Duration(a.MaxLatencyMillis)*time.Millisecond {
+		fails = append(fails, assertMaxLatency)
+	}
+
+	if !a.needsBody() {
+		return fails
+	}
+	//put the body back for anyone else looking at the response.
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	if a.BodyContains != "" && !bytes.Contains(body, []byte(a.BodyContains)) {
+		fails = append(fails, assertBodyContains)
+	}
+	if a.bodyRegex != nil && !a.bodyRegex.Match(body) {
+		fails = append(fails, assertBodyRegex)
+	}
+	for _, j := range a.JsonPath {
+		if v, ok := jsonPath(body, j.Path); !ok || v != j.Equals {
+			fails = append(fails, fmt.Sprintf(assertJsonPath, j.Path))
+		}
+	}
+	if a.MaxBodyBytes > 0 && int64(len(body)) > a.MaxBodyBytes {
+		fails = append(fails, assertMaxBodyBytes)
+	}
+	return fails
+}
@@ -0,0 +1,134 @@
+package p0d
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type codeRangeTest struct {
+	n   string
+	c   CodeRange
+	lo  int
+	hi  int
+	err bool
+}
+
+func TestCodeRangeParse(t *testing.T) {
+	tests := []codeRangeTest{
+		{n: "code", c: "200", lo: 200, hi: 200},
+		{n: "class", c: "2xx", lo: 200, hi: 299},
+		{n: "class upper", c: "4XX", lo: 400, hi: 499},
+		{n: "range", c: "200-204", lo: 200, hi: 204},
+		{n: "bad class", c: "9xx", err: true},
+		{n: "bad range", c: "300-200", err: true},
+		{n: "bad code", c: "ok", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			lo, hi, e := tt.c.parse()
+			if tt.err {
+				if e == nil {
+					t.Error("should not parse")
+				}
+				return
+			}
+			if e != nil || lo != tt.lo || hi != tt.hi {
+				t.Errorf("want %v-%v got %v-%v %v", tt.lo, tt.hi, lo, hi, e)
+			}
+		})
+	}
+}
+
+func TestAssertionsConfigValidate(t *testing.T) {
+	cfg := loadConfigFromFile("./examples/config_assertions.yml")
+	cfg.validate()
+
+	a := cfg.Res.Assertions
+	if len(a.codes) != 2 || a.codes[1] != [2]int{304, 304} {
+		t.Errorf("assertion codes incorrectly parsed, got %v", a.codes)
+	}
+	if a.bodyRegex == nil || len(a.JsonPath) != 1 || a.JsonPath[0].Equals != "ok" {
+		t.Error("assertions incorrectly parsed")
+	}
+	if a.MaxBodyBytes != 65536 || a.MaxLatencyMillis != 500 || len(a.Headers) != 2 {
+		t.Error("assertion limits incorrectly parsed")
+	}
+	if !cfg.Res.matchesCode(204) || !cfg.Res.matchesCode(304) || cfg.Res.matchesCode(301) {
+		t.Error("assertion codes should decide matching codes")
+	}
+}
+
+func TestAssert(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/error" {
+			w.Write([]byte(`{"status": "error", "id": "none"}`))
+			return
+		}
+		w.Write([]byte(`{"status": "ok", "id": 12}`))
+	}))
+	defer svr.Close()
+
+	cfg := Config{
+		Res: Res{
+			Assertions: Assertions{
+				Codes:            []CodeRange{"2xx"},
+				Headers:          map[string]string{"Content-Type": "application/json", "X-Missing": ""},
+				BodyContains:     "status",
+				BodyRegex:        `"id":\s*\d+`,
+				JsonPath:         []JsonPathAssertion{{Path: "$.status", Equals: "ok"}},
+				MaxBodyBytes:     1024,
+				MaxLatencyMillis: 100,
+			},
+		},
+	}
+	cfg.validateAssertions()
+
+	res, _ := http.Get(svr.URL + "/ok")
+	fails := cfg.Res.assert(res, time.Millisecond)
+	if len(fails) != 1 || fails[0] != "header X-Missing" {
+		t.Errorf("only missing header should fail, got %v", fails)
+	}
+	if b, _ := io.ReadAll(res.Body); len(b) == 0 {
+		t.Error("body should be readable after assertions")
+	}
+	res.Body.Close()
+
+	delete(cfg.Res.Assertions.Headers, "X-Missing")
+	res, _ = http.Get(svr.URL + "/error")
+	fails = cfg.Res.assert(res, time.Second)
+	res.Body.Close()
+	want := map[string]bool{"body regex": true, "jsonPath $.status": true, "max latency": true}
+	if len(fails) != len(want) {
+		t.Errorf("want %v failed assertions, got %v", len(want), fails)
+	}
+	for _, f := range fails {
+		if !want[f] {
+			t.Errorf("unexpected failed assertion %v", f)
+		}
+	}
+}
+
+func TestUpdateStatsAssertions(t *testing.T) {
+	cfg := Config{Res: Res{Code: 200}}
+	s := NewReqStats(cfg)
+	now := time.Now()
+	s.setStart(now.Add(-time.Second))
+
+	s.update(ReqAtmpt{ResCode: 200}, now, cfg)
+	s.update(ReqAtmpt{ResCode: 200, AssertFails: []string{"body contains", "max latency"}}, now, cfg)
+	s.update(ReqAtmpt{ResCode: 200, AssertFails: []string{"max latency"}}, now, cfg)
+
+	if s.SumAssertionFailures != 2 {
+		t.Errorf("want 2 failed attempts got %v", s.SumAssertionFailures)
+	}
+	if s.AssertionFailTypes["max latency"] != 2 || s.AssertionFailTypes["body contains"] != 1 {
+		t.Errorf("failed assertion types incorrect, got %v", s.AssertionFailTypes)
+	}
+	if s.PctAssertionFailures < 66 || s.PctAssertionFailures > 67 {
+		t.Errorf("want 66.67%% failed got %v", s.PctAssertionFailures)
+	}
+}
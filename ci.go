@@ -0,0 +1,178 @@
+package p0d
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/acarl005/stripansi"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SummaryKv   = "kv"
+	SummaryJson = "json"
+)
+
+var ciProgressFields = []string{"reqs", "rps", "meanRps", "latencyP50Ns", "latencyP99Ns", "errors", "errorsPct",
+	"openConns", "concurrency"}
+
+type ciReporter struct {
+	p      *P0d
+	out    io.Writer
+	every  time.Duration
+	format string
+	last   time.Time
+}
+
+func NewCIReporter(p *P0d, every time.Duration, format string) Reporter {
+	if every <= 0 {
+		every = time.Second * 10
+	}
+	return &ciReporter{p: p, out: os.Stdout, every: every, format: format}
+}
+
+func (c *ciReporter) OnStart(cfg Config) {
+	c.printf("%s run %s", vs, c.p.ID)
+	if cfg.File != N {
+		c.printf("config loaded from '%s'", cfg.File)
+	}
+	c.printf("set test duration: %ds max concurrent TCP conn(s): %d", cfg.Exec.DurationSeconds, cfg.Exec.Concurrency)
+	if cfg.isRateMode() {
+		c.printf("set constant arrival rate: %d%s", cfg.Exec.Rate, perSecondMsg)
+	}
+	if !cfg.isScenario() && len(cfg.Reqs) == 0 {
+		c.printf("set URL %s (%s)", cfg.Req.Url, cfg.Req.Method)
+	}
+
+	//no spinner here, just wait for the inet test so it doesn't run alongside the load.
+	if msg := c.p.awaitInetTest(); msg != N {
+		c.printf("%s", msg)
+	}
+	c.printf("starting engines: %s", c.p.ID)
+}
+
+func (c *ciReporter) OnLog(msg string) {
+	c.printf("%s", stripansi.Strip(strings.TrimSuffix(msg, "\n")))
+}
+
+func (c *ciReporter) OnPhase(phase TimerPhase) {
+	c.printf("phase: %s", timerPhaseNames[phase])
+}
+
+func (c *ciReporter) OnAttempt(ra ReqAtmpt) {}
+
+func (c *ciReporter) OnSnapshot(s *ReqStats) {
+	now := time.Now()
+	if c.last.IsZero() {
+		c.last = now
+		return
+	}
+	if now.Sub(c.last) < c.every {
+		return
+	}
+	c.last = now
+	c.progress(now)
+}
+
+func (c *ciReporter) OnFinish(p *P0d) {
+	c.progress(p.Time.Stop)
+	if c.format == SummaryJson {
+		j, _ := json.Marshal(c.summary())
+		fmt.Fprintf(c.out, "%s\n", j)
+		return
+	}
+	for _, kv := range c.summaryKv() {
+		fmt.Fprintf(c.out, "%s=%s\n", kv[0], kv[1])
+	}
+}
+
+func (c *ciReporter) progress(now time.Time) {
+	elpsd := now.Sub(c.p.Time.Start).Round(time.Second)
+	fields := make(map[string]string)
+	for _, f := range c.p.snapshotFields() {
+		fields[f.name] = f.String()
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "progress %s/%ds phase=%s", elpsd, c.p.Config.Exec.DurationSeconds, timerPhaseNames[c.p.Time.Phase])
+	for _, k := range ciProgressFields {
+		fmt.Fprintf(&b, " %s=%s", k, fields[k])
+	}
+	c.printf("%s", b.String())
+}
+
+func (c *ciReporter) summary() map[string]any {
+	s := map[string]any{
+		"id":             c.p.ID,
+		"exitCode":       c.p.ExitCode(),
+		"interrupted":    c.p.Interrupted,
+		"elapsedSeconds": c.p.Time.Stop.Sub(c.p.Time.Start).Seconds(),
+		"errorTypes":     c.p.ReqStats.ErrorTypes,
+	}
+	if c.p.AbortReason != N {
+		s["abortReason"] = c.p.AbortReason
+	}
+	if c.p.Verdict != nil {
+		s["pass"] = c.p.Verdict.Pass
+		s["thresholds"] = c.p.Verdict.Thresholds
+	}
+	for _, f := range c.p.snapshotFields() {
+		if f.isInt {
+			s[f.name] = int64(f.value)
+		} else {
+			s[f.name] = f.value
+		}
+	}
+	return s
+}
+
+func (c *ciReporter) summaryKv() [][2]string {
+	kv := [][2]string{
+		{"id", c.p.ID},
+		{"exitCode", strconv.Itoa(c.p.ExitCode())},
+		{"interrupted", strconv.FormatBool(c.p.Interrupted)},
+		{"elapsedSeconds", strconv.FormatFloat(c.p.Time.Stop.Sub(c.p.Time.Start).Seconds(), 'f', 2, 64)},
+	}
+	if c.p.AbortReason != N {
+		kv = append(kv, [2]string{"abortReason", strconv.Quote(c.p.AbortReason)})
+	}
+	if c.p.Verdict != nil {
+		kv = append(kv, [2]string{"pass", strconv.FormatBool(c.p.Verdict.Pass)})
+	}
+	for _, f := range c.p.snapshotFields() {
+		kv = append(kv, [2]string{f.name, f.String()})
+	}
+	errs := make([]string, 0, len(c.p.ReqStats.ErrorTypes))
+	for k := range c.p.ReqStats.ErrorTypes {
+		errs = append(errs, k)
+	}
+	sort.Strings(errs)
+	for _, k := range errs {
+		kv = append(kv, [2]string{"error." + kvKey(k), strconv.Itoa(c.p.ReqStats.ErrorTypes[k])})
+	}
+	if c.p.Verdict != nil {
+		for _, t := range c.p.Verdict.Thresholds {
+			kv = append(kv, [2]string{"threshold." + kvKey(t.Threshold), strconv.FormatBool(t.Pass)})
+		}
+	}
+	return kv
+}
+
+func (c *ciReporter) printf(s string, args ...any) {
+	fmt.Fprintf(c.out, "%s %s\n", time.Now().Format(time.TimeOnly), fmt.Sprintf(s, args...))
+}
+
+func (f snapshotField) String() string {
+	if f.isInt {
+		return strconv.FormatInt(int64(f.value), 10)
+	}
+	return strconv.FormatFloat(f.value, 'f', 2, 64)
+}
+
+func kvKey(s string) string {
+	//keys can't have spaces or equals signs, everything else is left as is.
+	return strings.NewReplacer(" ", "_", "=", "_").Replace(s)
+}
@@ -0,0 +1,71 @@
+package p0d
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ciSummaryTest struct {
+	format string
+	want   string
+}
+
+func TestCIReporter(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	tests := []ciSummaryTest{
+		{SummaryKv, "exitCode=130\n"},
+		{SummaryJson, `"exitCode":130`},
+	}
+	for _, tt := range tests {
+		p := newTestP0d(t, svr.URL, "")
+		out := bytes.Buffer{}
+		c := NewCIReporter(p, time.Millisecond*500, tt.format).(*ciReporter)
+		c.out = &out
+		p.SetReporters(c)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*1500)
+		p.Race(ctx)
+		cancel()
+
+		o := out.String()
+		if strings.Contains(o, "\x1b[") {
+			t.Errorf("%s output should have no escape codes, got %q", tt.format, o)
+		}
+		if !strings.Contains(o, "starting engines") || !strings.Contains(o, "phase: done") ||
+			!strings.Contains(o, "progress ") || !strings.Contains(o, "exiting") {
+			t.Errorf("%s output should log plain progress, got %s", tt.format, o)
+		}
+		if !strings.Contains(o, tt.want) {
+			t.Errorf("%s summary should contain %s, got %s", tt.format, tt.want, o)
+		}
+		if tt.format == SummaryJson {
+			var s map[string]any
+			for _, l := range strings.Split(o, "\n") {
+				if strings.HasPrefix(l, "{") {
+					if e := json.Unmarshal([]byte(l), &s); e != nil {
+						t.Errorf("json summary should parse, got %v", e)
+					}
+				}
+			}
+			if s["reqs"] != float64(p.ReqStats.ReqAtmpts) {
+				t.Errorf("json summary should have req count %d, got %v", p.ReqStats.ReqAtmpts, s["reqs"])
+			}
+		}
+	}
+}
+
+func TestKvKey(t *testing.T) {
+	if kvKey("p99 < 250ms") != "p99_<_250ms" || kvKey("a=b") != "a_b" {
+		t.Error("kv key incorrect")
+	}
+}
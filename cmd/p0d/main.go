@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/simonmittag/p0d"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 type Mode uint8
@@ -14,15 +18,22 @@ const (
 	File
 	Usage
 	Version
+	Report
+	Compare
+	Agent
+	Coordinate
 )
 
 var pattern = "/mse6/"
 
+const agentTokenEnv = "P0D_AGENT_TOKEN"
+
 func main() {
 	var mode Mode
 
 	C := flag.String("C", "", "load configuration from yml file")
 	O := flag.String("O", "", "save detailed output to json file")
+	R := flag.String("R", "", "save html report to file")
 	c := flag.Int("c", 1, "maximum amount of concurrent TCP connections used")
 	d := flag.Int("d", 10, "time in seconds to run p0d")
 	H := flag.String("H", "1.1", "http version to use. Values are 1.1 and 2 (which works only with "+
@@ -30,12 +41,25 @@ func main() {
 	s := flag.Bool("s", false, "skip internet speed test i.e. for local targets")
 	h := flag.Bool("h", false, "print usage instructions")
 	v := flag.Bool("v", false, "print version")
+	ci := flag.Bool("ci", false, "plain text progress and a machine readable summary, no live log. On when stdout is not a terminal")
+	flag.BoolVar(ci, "quiet", false, "same as -ci")
+	tui := flag.Bool("tui", false, "full screen dashboard with live charts and keys to pause, change concurrency and stop")
+	progress := flag.Int("progress", 10, "seconds between progress lines in -ci mode")
+	summary := flag.String("summary", p0d.SummaryKv, "format of the -ci summary. Values are kv and json")
 	var u string
 
 	flag.Parse()
 
 	if *v {
 		mode = Version
+	} else if flag.Arg(0) == "report" {
+		mode = Report
+	} else if flag.Arg(0) == "compare" {
+		mode = Compare
+	} else if flag.Arg(0) == "agent" {
+		mode = Agent
+	} else if flag.Arg(0) == "coordinate" {
+		mode = Coordinate
 	} else if *h || (flag.NFlag() == 0 && len(flag.Args()) == 0) {
 		mode = Usage
 	} else if len(*C) > 0 {
@@ -49,21 +73,147 @@ func main() {
 	switch mode {
 	case Cli:
 		pod = p0d.NewP0dWithValues(*c, *d, u, *H, *O, *s)
-		pod.Race()
+		pod.Report = *R
+		output(pod, *ci, *tui, *progress, *summary)
+		pod.Race(context.Background())
 	case File:
 		pod = p0d.NewP0dFromFile(*C, *O)
-		pod.Race()
+		pod.Report = *R
+		output(pod, *ci, *tui, *progress, *summary)
+		pod.Race(context.Background())
+	case Report:
+		writeReport(*R)
+	case Compare:
+		compare()
+	case Agent:
+		agent(*ci, *progress, *summary)
+	case Coordinate:
+		pod = coordinate(*ci, *tui, *progress, *summary)
 	case Usage:
 		printUsage()
 	case Version:
 		printVersion()
 	}
 
-	if mode == Cli || mode == File {
-		if pod == nil || pod.ReqStats.SumErrors > 0 || pod.Interrupted {
-			os.Exit(-1)
+	if mode == Cli || mode == File || mode == Coordinate {
+		if pod == nil {
+			os.Exit(p0d.ExitErrors)
+		}
+		if code := pod.ExitCode(); code != p0d.ExitOK {
+			os.Exit(code)
+		}
+	}
+}
+
+func output(pod *p0d.P0d, ci bool, tui bool, progress int, summary string) {
+	if tui && !ci {
+		if !isTerminal(os.Stdout) || !isTerminal(os.Stdin) {
+			fmt.Fprintln(os.Stderr, "tui needs a terminal, use -ci instead")
+			os.Exit(p0d.ExitConfigError)
 		}
+		pod.SetReporters(p0d.NewTUIReporter(pod))
+		return
+	}
+	//redraws and colors are noise in build logs, so we don't wait to be asked.
+	if !ci && isTerminal(os.Stdout) {
+		return
+	}
+	if summary != p0d.SummaryKv && summary != p0d.SummaryJson {
+		fmt.Fprintf(os.Stderr, "summary must be one of %s, %s, yours: %s\n", p0d.SummaryKv, p0d.SummaryJson, summary)
+		os.Exit(p0d.ExitConfigError)
+	}
+	pod.SetReporters(p0d.NewCIReporter(pod, time.Duration(progress)*time.Second, summary))
+}
+
+func isTerminal(f *os.File) bool {
+	fi, e := f.Stat()
+	return e == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func writeReport(out string) {
+	//p0d report run.json [report.html], or -R before the subcommand
+	in := flag.Arg(1)
+	if in == "" {
+		printUsage()
+		os.Exit(p0d.ExitConfigError)
+	}
+	if flag.Arg(2) != "" {
+		out = flag.Arg(2)
+	}
+	if out == "" {
+		out = strings.TrimSuffix(in, filepath.Ext(in)) + ".html"
+	}
+	if e := p0d.WriteReport(in, out); e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(p0d.ExitErrors)
+	}
+	fmt.Printf("report written to %s\n", out)
+}
+
+func compare() {
+	//p0d compare [-rps 10] [-latency 10] [-bytes 10] [-errors 1] baseline.json candidate.json
+	tol := p0d.NewTolerances()
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Float64Var(&tol.RpsPct, "rps", tol.RpsPct, "tolerated drop in throughput, in percent")
+	fs.Float64Var(&tol.LatencyPct, "latency", tol.LatencyPct, "tolerated rise in latency, in percent")
+	fs.Float64Var(&tol.BytesPct, "bytes", tol.BytesPct, "tolerated drop in bytes read and written per second, in percent")
+	fs.Float64Var(&tol.ErrorsPct, "errors", tol.ErrorsPct, "tolerated rise in error rates, in percentage points")
+	fs.Parse(flag.Args()[1:])
+	if fs.NArg() != 2 {
+		fmt.Print("usage: p0d compare [-f flag] baseline.json candidate.json\n\n flags:\n")
+		fs.PrintDefaults()
+		os.Exit(p0d.ExitConfigError)
+	}
+	cmp, e := p0d.Compare(fs.Arg(0), fs.Arg(1), tol)
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(p0d.ExitErrors)
+	}
+	cmp.Log()
+	if cmp.Regression {
+		os.Exit(p0d.ExitRegression)
+	}
+}
+
+func agent(ci bool, progress int, summary string) {
+	//p0d agent [-listen :7000] [-token secret]
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	listen := fs.String("listen", ":7000", "address to wait for runs from a coordinator on")
+	token := fs.String("token", os.Getenv(agentTokenEnv), "shared secret coordinators must send. Generated if not set")
+	fs.Parse(flag.Args()[1:])
+	a := p0d.NewAgent(*listen, *token)
+	//agents take one run after another, there's nobody to watch a dashboard.
+	a.Output = func(pod *p0d.P0d) {
+		output(pod, ci, false, progress, summary)
+	}
+	if e := a.ListenAndServe(); e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(p0d.ExitErrors)
+	}
+}
+
+func coordinate(ci bool, tui bool, progress int, summary string) *p0d.P0d {
+	//p0d coordinate -C cfg.yml -agents a:7000,b:7000 -token secret [-O run.json] [-R report.html]
+	fs := flag.NewFlagSet("coordinate", flag.ExitOnError)
+	C := fs.String("C", "", "load configuration from yml file")
+	O := fs.String("O", "", "save detailed output to json file")
+	R := fs.String("R", "", "save html report to file")
+	agents := fs.String("agents", "", "comma separated agents to split the load across, i.e. a:7000,b:7000")
+	token := fs.String("token", os.Getenv(agentTokenEnv), "shared secret of the agents")
+	fs.Parse(flag.Args()[1:])
+	if *C == "" || *agents == "" || *token == "" {
+		fmt.Print("usage: p0d coordinate -C cfg.yml -agents host:port[,host:port] -token secret [-f flag]\n\n flags:\n")
+		fs.PrintDefaults()
+		os.Exit(p0d.ExitConfigError)
+	}
+	pod := p0d.NewP0dFromFile(*C, *O)
+	pod.Report = *R
+	output(pod, ci, tui, progress, summary)
+	if e := pod.Coordinate(strings.Split(*agents, ","), *token); e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(p0d.ExitConfigError)
 	}
+	return pod
 }
 
 func printVersion() {
@@ -73,6 +223,6 @@ func printVersion() {
 func printUsage() {
 	p0d.PrintLogo()
 	p0d.PrintVersion()
-	fmt.Print("\nusage: p0d [-f flag] [URL]\n\n flags:\n")
+	fmt.Print("\nusage: p0d [-f flag] [URL]\n       p0d report run.json [report.html]\n       p0d compare [-f flag] baseline.json candidate.json\n       p0d agent [-listen :7000] [-token secret]\n       p0d coordinate -C cfg.yml -agents host:port[,host:port] -token secret [-f flag]\n\n flags:\n")
 	flag.PrintDefaults()
 }
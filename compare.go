@@ -0,0 +1,162 @@
+package p0d
+
+import (
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"math"
+)
+
+type Tolerances struct {
+	RpsPct     float64
+	LatencyPct float64
+	BytesPct   float64
+	ErrorsPct  float64
+}
+
+func NewTolerances() Tolerances {
+	return Tolerances{
+		RpsPct:     10,
+		LatencyPct: 10,
+		BytesPct:   10,
+		ErrorsPct:  1,
+	}
+}
+
+type compareKind int
+
+const (
+	//throughput should not drop
+	higherBetter compareKind = iota
+	//latency should not rise
+	lowerBetter
+	//error rates are compared in percentage points, not relative to themselves
+	pctPoints
+)
+
+type Delta struct {
+	Metric     string
+	Baseline   float64
+	Candidate  float64
+	DeltaPct   float64
+	Regression bool
+
+	kind compareKind
+	unit func(float64) string
+}
+
+type Comparison struct {
+	Baseline   string
+	Candidate  string
+	Deltas     []Delta
+	Regression bool
+}
+
+func Compare(baseline string, candidate string, tol Tolerances) (*Comparison, error) {
+	b, e := loadRun(baseline)
+	if e != nil {
+		return nil, e
+	}
+	c, e := loadRun(candidate)
+	if e != nil {
+		return nil, e
+	}
+	cmp := compareRuns(b, c, tol)
+	cmp.Baseline = baseline
+	cmp.Candidate = candidate
+	return cmp, nil
+}
+
+func compareRuns(b *reportRun, c *reportRun, tol Tolerances) *Comparison {
+	cmp := &Comparison{}
+	add := func(metric string, kind compareKind, tolPct float64, bv float64, cv float64, f func(float64) string) {
+		d := Delta{Metric: metric, Baseline: bv, Candidate: cv, kind: kind, unit: f}
+		switch kind {
+		case pctPoints:
+			d.DeltaPct = cv - bv
+			d.Regression = d.DeltaPct > tolPct
+		default:
+			//no baseline means nothing to regress from
+			if bv > 0 {
+				d.DeltaPct = 100 * (cv - bv) / bv
+				if kind == higherBetter {
+					d.Regression = d.DeltaPct < -tolPct
+				} else {
+					d.Regression = d.DeltaPct > tolPct
+				}
+			}
+		}
+		cmp.Regression = cmp.Regression || d.Regression
+		cmp.Deltas = append(cmp.Deltas, d)
+	}
+	rps := func(v float64) string { return FGroup(int64(v)) + "/s" }
+	bps := func(v float64) string { return ByteCountIEC(int64(v)) + "/s" }
+	pct := func(v float64) string { return fmt.Sprintf("%.2f%%", v) }
+
+	bs, cs := b.ReqStats, c.ReqStats
+	add("mean throughput", higherBetter, tol.RpsPct, float64(bs.MeanReqAtmptsPSec), float64(cs.MeanReqAtmptsPSec), rps)
+	add("max throughput", higherBetter, tol.RpsPct, float64(bs.MaxReqAtmptsPSec), float64(cs.MaxReqAtmptsPSec), rps)
+	for _, q := range quantileKeys {
+		add("latency "+q, lowerBetter, tol.LatencyPct,
+			nsValue(bs.ElpsdAtmptLatencyNsQuantiles[q]), nsValue(cs.ElpsdAtmptLatencyNsQuantiles[q]), fmtNsFloat)
+	}
+	for _, q := range quantileKeys {
+		add("corrected latency "+q, lowerBetter, tol.LatencyPct,
+			nsValue(bs.ElpsdAtmptCorrectedLatencyNsQuantiles[q]), nsValue(cs.ElpsdAtmptCorrectedLatencyNsQuantiles[q]), fmtNsFloat)
+	}
+	add("transport errors", pctPoints, tol.ErrorsPct, float64(bs.PctErrors), float64(cs.PctErrors), pct)
+	add("failed assertions", pctPoints, tol.ErrorsPct, float64(bs.PctAssertionFailures), float64(cs.PctAssertionFailures), pct)
+	add("mismatched HTTP response codes", pctPoints, tol.ErrorsPct,
+		mismatchedPct(bs), mismatchedPct(cs), pct)
+	add("read throughput", higherBetter, tol.BytesPct, float64(bs.MeanBytesReadPSec), float64(cs.MeanBytesReadPSec), bps)
+	add("write throughput", higherBetter, tol.BytesPct, float64(bs.MeanBytesWrittenPSec), float64(cs.MeanBytesWrittenPSec), bps)
+	return cmp
+}
+
+func nsValue(ns int64) float64 {
+	//quantiles of runs without any requests come out as garbage, treat them as missing.
+	if ns < 0 {
+		return 0
+	}
+	return float64(ns)
+}
+
+func mismatchedPct(s reportStats) float64 {
+	if s.ReqAtmpts == 0 {
+		return 0
+	}
+	return 100 - float64(s.PctMatchingResponseCodes)
+}
+
+func (d Delta) format() string {
+	switch {
+	case d.kind == pctPoints:
+		return fmt.Sprintf("%+.2fpp", d.DeltaPct)
+	case d.Baseline == 0:
+		return "n/a"
+	default:
+		return fmt.Sprintf("%+.2f%%", d.DeltaPct)
+	}
+}
+
+const compareMsg = "%-34s %14s %14s %10s"
+
+func (cmp *Comparison) Log() {
+	log("baseline:  %s", Yellow(cmp.Baseline))
+	log("candidate: %s", Yellow(cmp.Candidate))
+	logv(fmt.Sprintf(compareMsg, "metric", "baseline", "candidate", "delta"))
+	for _, d := range cmp.Deltas {
+		l := fmt.Sprintf(compareMsg, d.Metric, d.unit(d.Baseline), d.unit(d.Candidate), d.format())
+		if d.Regression {
+			logv(Red(l + " regression"))
+		} else if d.DeltaPct == 0 || math.IsNaN(d.DeltaPct) {
+			logv(l)
+		} else {
+			logv(Cyan(l))
+		}
+	}
+	if cmp.Regression {
+		logv(Red("compare: regression"))
+	} else {
+		logv(Cyan("compare: no regression"))
+	}
+}
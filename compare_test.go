@@ -0,0 +1,97 @@
+package p0d
+
+import (
+	"os"
+	"testing"
+)
+
+func compareRun(rps int64, p99 int64, pctErrors float32) *reportRun {
+	r := &reportRun{ID: "run"}
+	r.ReqStats.ReqAtmpts = 100
+	r.ReqStats.MeanReqAtmptsPSec = rps
+	r.ReqStats.MaxReqAtmptsPSec = rps
+	r.ReqStats.MeanBytesReadPSec = rps * 100
+	r.ReqStats.MeanBytesWrittenPSec = rps * 100
+	r.ReqStats.PctMatchingResponseCodes = 100
+	r.ReqStats.PctErrors = pctErrors
+	r.ReqStats.ElpsdAtmptLatencyNsQuantiles = map[string]int64{"p50": 1000, "p99": p99}
+	r.ReqStats.ElpsdAtmptCorrectedLatencyNsQuantiles = map[string]int64{"p50": 1000, "p99": p99}
+	return r
+}
+
+type compareTest struct {
+	name       string
+	candidate  *reportRun
+	regression string
+}
+
+func TestCompareRuns(t *testing.T) {
+	baseline := compareRun(1000, 10000, 0.5)
+	tests := []compareTest{
+		{"same", compareRun(1000, 10000, 0.5), ""},
+		{"within tolerance", compareRun(950, 10900, 1.4), ""},
+		{"slower", compareRun(1000, 11500, 0.5), "latency p99"},
+		{"less throughput", compareRun(800, 10000, 0.5), "mean throughput"},
+		{"more errors", compareRun(1000, 10000, 2), "transport errors"},
+		{"faster", compareRun(2000, 5000, 0), ""},
+	}
+	for _, tt := range tests {
+		cmp := compareRuns(baseline, tt.candidate, NewTolerances())
+		if cmp.Regression != (tt.regression != N) {
+			t.Errorf("%s: regression should be %v", tt.name, tt.regression != N)
+		}
+		if tt.regression != N {
+			found := false
+			for _, d := range cmp.Deltas {
+				if d.Metric == tt.regression && d.Regression {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s: %s should regress", tt.name, tt.regression)
+			}
+		}
+	}
+}
+
+func TestCompareDeltas(t *testing.T) {
+	cmp := compareRuns(compareRun(1000, 10000, 0.5), compareRun(1100, 5000, 1), NewTolerances())
+	for _, d := range cmp.Deltas {
+		switch d.Metric {
+		case "mean throughput":
+			if d.DeltaPct != 10 || d.format() != "+10.00%" {
+				t.Errorf("throughput delta incorrect, got %v", d.format())
+			}
+		case "latency p99":
+			if d.DeltaPct != -50 {
+				t.Errorf("latency delta incorrect, got %v", d.DeltaPct)
+			}
+		case "latency max":
+			if d.format() != "n/a" {
+				t.Errorf("missing baseline should not have a delta, got %v", d.format())
+			}
+		case "transport errors":
+			if d.format() != "+0.50pp" {
+				t.Errorf("errors delta should be in percentage points, got %v", d.format())
+			}
+		}
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	os.WriteFile("testbaseline.json", []byte(`[{"ID":"a","ReqStats":{"MeanReqAtmptsPSec":100}}]`), 0644)
+	os.WriteFile("testcandidate.json", []byte(`[{"ID":"b","ReqStats":{"MeanReqAtmptsPSec":50}}]`), 0644)
+	defer os.Remove("testbaseline.json")
+	defer os.Remove("testcandidate.json")
+
+	cmp, e := Compare("testbaseline.json", "testcandidate.json", NewTolerances())
+	if e != nil || !cmp.Regression {
+		t.Errorf("should load both runs and regress, got %v", e)
+	}
+	cmp.Log()
+
+	_, e = Compare("testbaseline.json", "doesnotexist.json", NewTolerances())
+	if e == nil {
+		t.Error("should fail on missing candidate")
+	}
+}
@@ -10,23 +10,35 @@ import (
 	"golang.org/x/net/http2"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
 type Config struct {
-	Req  Req
-	Res  Res
-	Exec Exec
-	File string
+	Req        Req
+	Reqs       []Req
+	Scenario   Scenario
+	Res        Res
+	Exec       Exec
+	Thresholds []string
+	Abort      []string
+	File       string
+
+	seq        *int64
+	thresholds []Threshold
+	aborts     []AbortCondition
 }
 
 type Req struct {
+	Name          string
+	Weight        int
 	Method        string
 	Url           string
 	Headers       []map[string]string
@@ -34,11 +46,15 @@ type Req struct {
 	Body          string
 	FormData      []map[string]string
 	FormDataFiles map[string][]byte
+	Feeder        *Feeder
 	Ips           []net.IP
+
+	tmpls map[string]*template.Template
 }
 
 type Res struct {
-	Code int
+	Code       int
+	Assertions Assertions
 }
 
 type Exec struct {
@@ -46,11 +62,27 @@ type Exec struct {
 	DurationSeconds    int
 	RampSeconds        int
 	Concurrency        int
+	Rate               int
 	DialTimeoutSeconds int64
 	LogSampling        float64
 	SpacingMillis      int64
 	HttpVersion        float32
 	SkipInetTest       bool
+	Stages             []Stage
+	MetricsListen      string
+	Push               Push
+	Tracing            Tracing
+	SeriesSeconds      int
+	RequestLog         string
+	HdrLog             string
+	Histogram          Histogram
+}
+
+type Stage struct {
+	Name            string
+	DurationSeconds int
+	Concurrency     int
+	Rate            int
 }
 
 const UNLIMITED int = -1
@@ -60,37 +92,80 @@ const http20 = 2
 
 var httpVers = map[float32]float32{http11: http11, http20: http20}
 
-func loadConfigFromFile(fileName string) *Config {
-	cfgPanic := func(err error) {
-		if err != nil {
-			msg := Red(fmt.Sprintf("unable to load config from '%s', exiting...", fileName))
-			logv(msg)
-			os.Exit(-1)
-		}
-	}
+type ConfigError struct {
+	msg string
+}
+
+func (e ConfigError) Error() string {
+	return e.msg
+}
 
+func LoadConfig(fileName string) (*Config, error) {
 	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, ConfigError{fmt.Sprintf("unable to load config from '%s': %s", fileName, err.Error())}
+	}
 	defer f.Close()
-	cfgPanic(err)
 
 	yml, _ := ioutil.ReadAll(f)
+	c, err := parseConfig(yml)
+	if err != nil {
+		return nil, ConfigError{fmt.Sprintf("unable to load config from '%s': %s", fileName, err.Error())}
+	}
+	c.File = fileName
+	return c, nil
+}
+
+func loadConfigFromFile(fileName string) *Config {
+	c, e := LoadConfig(fileName)
+	exitOnConfigError(e)
+	return c
+}
+
+func parseConfig(yml []byte) (*Config, error) {
 	jsn, _ := yaml.YAMLToJSON(yml)
 
 	c := &Config{}
-	err = json.Unmarshal(jsn, c)
-	cfgPanic(err)
-	return c
+	if e := json.Unmarshal(jsn, c); e != nil {
+		return nil, e
+	}
+	return c, nil
 }
 
-func (cfg *Config) validate() *Config {
-	//we always want this.
-	cfg.Req.Method = strings.ToUpper(cfg.Req.Method)
-	if cfg.Req.Method == "" {
-		cfg.Req.Method = "GET"
+func (cfg *Config) Validate() (err error) {
+	defer recoverConfigError(&err)
+	cfg.validate()
+	return nil
+}
+
+func recoverConfigError(err *error) {
+	//validation bails out with cfg.panic wherever it is, we hand that back as the error.
+	if r := recover(); r != nil {
+		ce, ok := r.(ConfigError)
+		if !ok {
+			panic(r)
+		}
+		*err = ce
 	}
+}
+
+func exitOnConfigError(err error) {
+	if err != nil {
+		logv(Red(err.Error()))
+		os.Exit(ExitConfigError)
+	}
+}
+
+func (cfg *Config) validate() *Config {
+	//stages overwrite duration and concurrency or rate, so we need them first.
+	cfg.validateStages()
 
 	if cfg.Exec.Concurrency == 0 {
 		cfg.Exec.Concurrency = 1
+		//an open model needs enough workers for the rate, not one.
+		if cfg.isRateMode() {
+			cfg.Exec.Concurrency = cfg.ratePoolSize()
+		}
 	}
 	if cfg.Exec.DurationSeconds == 0 {
 		cfg.Exec.DurationSeconds = 10
@@ -100,13 +175,19 @@ func (cfg *Config) validate() *Config {
 		}
 	}
 
-	if cfg.Exec.RampSeconds == 0 {
+	if cfg.hasStages() {
+		//stages describe their own ramps
+		cfg.Exec.RampSeconds = 0
+	} else if cfg.Exec.RampSeconds == 0 {
 		cfg.Exec.RampSeconds = int(math.Ceil(float64(cfg.Exec.DurationSeconds) / 10))
 	} else {
 		if float64(cfg.Exec.RampSeconds) > (float64(cfg.Exec.DurationSeconds) / 2) {
 			cfg.panic("ramp time cannot be longer than half the duration")
 		}
 	}
+	if cfg.Exec.Rate < 0 {
+		cfg.panic("rate cannot be negative")
+	}
 	if cfg.Exec.DialTimeoutSeconds == 0 {
 		cfg.Exec.DialTimeoutSeconds = 3
 	}
@@ -129,13 +210,109 @@ func (cfg *Config) validate() *Config {
 		//default to all
 		cfg.Exec.SpacingMillis = 0
 	}
+	if cfg.Exec.SeriesSeconds < 0 {
+		cfg.panic("series interval cannot be negative")
+	}
+	if cfg.Exec.SeriesSeconds == 0 {
+		cfg.Exec.SeriesSeconds = 1
+	}
 
-	cfg.validateReqBody()
+	if cfg.isScenario() {
+		if len(cfg.Reqs) > 0 {
+			cfg.panic("when specifying a scenario, cannot specify reqs, use scenario steps")
+		}
+		cfg.validateScenario()
+	} else if len(cfg.Reqs) > 0 {
+		for i := range cfg.Reqs {
+			r := &cfg.Reqs[i]
+			if r.Name == "" {
+				r.Name = fmt.Sprintf("req %d", i+1)
+			}
+			if r.Weight < 0 {
+				cfg.panic(fmt.Sprintf("%s weight cannot be negative", r.Name))
+			}
+			if r.Weight == 0 {
+				r.Weight = 1
+			}
+			cfg.validateReq(r)
+		}
+		cfg.validateReqNames()
+		//the first template stands in wherever we need a single request, i.e. detecting remote conn settings
+		cfg.Req = cfg.Reqs[0]
+	} else {
+		cfg.validateReq(&cfg.Req)
+	}
+
+	if cfg.Res.Code == 0 {
+		cfg.Res.Code = 200
+	}
+	cfg.validateAssertions()
+	cfg.validateThresholds()
+	cfg.validateAbort()
+	cfg.validatePush()
+	cfg.validateTracing()
+	cfg.validateHistogram()
+	return cfg
+}
 
-	if cfg.Req.Url == "" {
+func (cfg *Config) hasStages() bool {
+	return len(cfg.Exec.Stages) > 0
+}
+
+func (cfg *Config) validateStages() {
+	if !cfg.hasStages() {
+		return
+	}
+
+	d, c, r := 0, 0, 0
+	for i := range cfg.Exec.Stages {
+		s := &cfg.Exec.Stages[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("stage %d", i+1)
+		}
+		if s.DurationSeconds < 1 {
+			cfg.panic(fmt.Sprintf("%s duration cannot be less than 1 second", s.Name))
+		}
+		if s.Concurrency < 0 || s.Rate < 0 {
+			cfg.panic(fmt.Sprintf("%s target cannot be negative", s.Name))
+		}
+		d += s.DurationSeconds
+		if s.Concurrency > c {
+			c = s.Concurrency
+		}
+		if s.Rate > r {
+			r = s.Rate
+		}
+	}
+
+	if c > 0 && r > 0 {
+		cfg.panic("stages can target either concurrency or rate, not both")
+	}
+	if r == 0 && cfg.Exec.Rate > 0 {
+		cfg.panic("exec.rate cannot be combined with stages that target concurrency, set a rate on the stages instead")
+	}
+	if r > 0 {
+		//concurrency remains the size of the connection pool the scheduler dispatches onto
+		cfg.Exec.Rate = r
+	} else {
+		cfg.Exec.Concurrency = c
+	}
+	cfg.Exec.DurationSeconds = d
+}
+
+func (cfg *Config) validateReq(r *Req) {
+	//we always want this.
+	r.Method = strings.ToUpper(r.Method)
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+
+	cfg.validateReqBody(r)
+
+	if r.Url == "" {
 		cfg.panic("request url not specified")
 	} else {
-		u, e := url.Parse(cfg.Req.Url)
+		u, e := url.Parse(r.Url)
 		if e != nil {
 			cfg.panic(e.Error())
 		}
@@ -144,7 +321,7 @@ func (cfg *Config) validate() *Config {
 			h = u.Host
 		}
 		if len(u.Scheme) == 0 {
-			cfg.Req.Url = "http://" + cfg.Req.Url
+			r.Url = "http://" + r.Url
 		}
 		if len(p) > 0 {
 			p1, e2 := strconv.Atoi(p)
@@ -156,29 +333,76 @@ func (cfg *Config) validate() *Config {
 			}
 		}
 
-		cfg.Req.Ips = make([]net.IP, 1)
-		cfg.Req.Ips[0] = net.ParseIP(h)
+		r.Ips = make([]net.IP, 1)
+		r.Ips[0] = net.ParseIP(h)
 
 		var e3 error
-		if cfg.Req.Ips[0] == nil {
-			cfg.Req.Ips, e3 = net.LookupIP(h)
+		if r.Ips[0] == nil {
+			r.Ips, e3 = net.LookupIP(h)
 		}
-		if e3 == nil && len(cfg.Req.Ips) > 0 {
-			if isPrivateIP(cfg.Req.Ips[0]) {
+		if e3 == nil && len(r.Ips) > 0 {
+			if isPrivateIP(r.Ips[0]) {
 				//we disable inet test for all local targets.
 				cfg.Exec.SkipInetTest = true
 			}
 		}
 	}
 
-	if cfg.Res.Code == 0 {
-		cfg.Res.Code = 200
+	cfg.validateFeeder(r)
+
+	//compile last, after the url and body have their final form.
+	cfg.compileTemplates(r)
+}
+
+func (cfg *Config) validateReqNames() {
+	names := make(map[string]bool)
+	for _, r := range cfg.reqs() {
+		if names[r.Name] {
+			cfg.panic(fmt.Sprintf("request name '%s' is not unique", r.Name))
+		}
+		names[r.Name] = true
 	}
-	return cfg
 }
 
-func (cfg *Config) getRemotePort() uint16 {
-	u, _ := url.Parse(cfg.Req.Url)
+func (cfg *Config) hasReqTemplates() bool {
+	return len(cfg.Reqs) > 0 || cfg.isScenario()
+}
+
+func (cfg *Config) reqs() []Req {
+	if cfg.isScenario() {
+		rs := make([]Req, 0, len(cfg.Scenario.Steps))
+		for _, s := range cfg.Scenario.Steps {
+			rs = append(rs, s.Req)
+		}
+		return rs
+	}
+	if len(cfg.Reqs) > 0 {
+		return cfg.Reqs
+	}
+	return []Req{cfg.Req}
+}
+
+func (cfg *Config) pickReq() *Req {
+	if len(cfg.Reqs) == 0 {
+		return &cfg.Req
+	}
+
+	sum := 0
+	for _, r := range cfg.Reqs {
+		sum += r.Weight
+	}
+	w := rand.Intn(sum)
+	for i := range cfg.Reqs {
+		w -= cfg.Reqs[i].Weight
+		if w < 0 {
+			return &cfg.Reqs[i]
+		}
+	}
+	return &cfg.Reqs[len(cfg.Reqs)-1]
+}
+
+func (r *Req) getRemotePort() uint16 {
+	u, _ := url.Parse(r.Url)
 	_, p, _ := net.SplitHostPort(u.Host)
 	if p == N {
 		if u.Scheme == "http" {
@@ -192,83 +416,83 @@ func (cfg *Config) getRemotePort() uint16 {
 	return uint16(p1)
 }
 
-func (cfg *Config) validateReqBody() {
-	if len(cfg.Req.Body) > 0 {
-		if len(cfg.Req.FormData) > 0 {
+func (cfg *Config) validateReqBody(r *Req) {
+	if len(r.Body) > 0 {
+		if len(r.FormData) > 0 {
 			cfg.panic("when specifying request body, cannot have form data")
 		}
 	}
 
-	if len(cfg.Req.FormData) > 0 {
-		if cfg.Req.Method != "POST" {
+	if len(r.FormData) > 0 {
+		if r.Method != "POST" {
 			cfg.panic("when specifying form data, method must be POST")
 		}
-		if len(cfg.Req.Body) > 0 {
+		if len(r.Body) > 0 {
 			cfg.panic("when specifying form data, cannot specify body, use formData param")
 		}
-		cfg.setDefaultFormDataContentType()
+		r.setDefaultFormDataContentType()
 
-		cfg.Req.FormDataFiles = make(map[string][]byte, 0)
-		for i, fd := range cfg.Req.FormData {
+		r.FormDataFiles = make(map[string][]byte, 0)
+		for i, fd := range r.FormData {
 			for k, v := range fd {
 				if strings.HasPrefix(k, "@") {
 					dat, err := os.ReadFile(v)
 					if err != nil {
 						cfg.panic(fmt.Sprintf("unable to read file: %s", v))
 					}
-					cfg.Req.FormDataFiles[k] = dat
+					r.FormDataFiles[k] = dat
 					f, _ := os.Open(v)
 					fs, _ := f.Stat()
-					cfg.Req.FormData[i] = map[string]string{k: fs.Name()}
+					r.FormData[i] = map[string]string{k: fs.Name()}
 					dat = nil
 				}
 			}
 		}
-	} else if contains(bodyTypes, cfg.Req.Method) {
-		cfg.setDefaultPostContentType()
+	} else if contains(bodyTypes, r.Method) {
+		r.setDefaultPostContentType()
 	}
 }
 
-func (cfg *Config) setDefaultPostContentType() {
-	cfg.setContentType("application/json", false)
+func (r *Req) setDefaultPostContentType() {
+	r.setContentType("application/json", false)
 }
 
-func (cfg *Config) setDefaultFormDataContentType() {
-	cfg.setContentType("application/x-www-form-urlencoded", false)
+func (r *Req) setDefaultFormDataContentType() {
+	r.setContentType("application/x-www-form-urlencoded", false)
 }
 
-func (cfg *Config) setContentType(contentType string, overwrite bool) {
+func (r *Req) setContentType(contentType string, overwrite bool) {
 	const ctkey = "Content-Type"
 	ctobj := map[string]string{ctkey: contentType}
 
-	if len(cfg.Req.Headers) > 0 {
+	if len(r.Headers) > 0 {
 		matched := false
-		for i, h := range cfg.Req.Headers {
+		for i, h := range r.Headers {
 			for k, v := range h {
 				if k == ctkey {
 					matched = true
 					if overwrite {
-						cfg.Req.Headers[i] = ctobj
-						cfg.Req.ContentType = contentType
+						r.Headers[i] = ctobj
+						r.ContentType = contentType
 					} else {
-						cfg.Req.Headers[i][ct] = v
-						cfg.Req.ContentType = v
+						r.Headers[i][ct] = v
+						r.ContentType = v
 					}
 				}
 			}
 		}
 		if !matched {
-			cfg.Req.Headers = append(cfg.Req.Headers, ctobj)
-			cfg.Req.ContentType = contentType
+			r.Headers = append(r.Headers, ctobj)
+			r.ContentType = contentType
 		}
 	} else {
-		cfg.Req.Headers = append(cfg.Req.Headers, ctobj)
-		cfg.Req.ContentType = contentType
+		r.Headers = append(r.Headers, ctobj)
+		r.ContentType = contentType
 	}
 }
 
-func (cfg *Config) hasContentType(contentType string) bool {
-	for _, h := range cfg.Req.Headers {
+func (r *Req) hasContentType(contentType string) bool {
+	for _, h := range r.Headers {
 		for k, v := range h {
 			if k == "Content-Type" {
 				if v != contentType {
@@ -277,7 +501,7 @@ func (cfg *Config) hasContentType(contentType string) bool {
 			}
 		}
 	}
-	if cfg.Req.ContentType != contentType {
+	if r.ContentType != contentType {
 		return false
 	}
 	return true
@@ -312,8 +536,11 @@ func (cfg Config) scaffoldHttpClientWith(max int, connSpy bool, pod *P0d) *http.
 	t := &http.Transport{
 		DisableCompression: true,
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			to := time.Duration(cfg.Exec.DialTimeoutSeconds) * time.Second
-			c1, e := net.DialTimeout(network, addr, to)
+			//dial with the request context so httptrace sees dns and connect.
+			nd := net.Dialer{
+				Timeout: time.Duration(cfg.Exec.DialTimeoutSeconds) * time.Second,
+			}
+			c1, e := nd.DialContext(ctx, network, addr)
 			if connSpy {
 				pod.sampleConn = c1
 			}
@@ -323,7 +550,7 @@ func (cfg Config) scaffoldHttpClientWith(max int, connSpy bool, pod *P0d) *http.
 			nd := net.Dialer{
 				Timeout: time.Duration(cfg.Exec.DialTimeoutSeconds) * time.Second,
 			}
-			c1, e := tls.DialWithDialer(&nd, network, addr, tlsc)
+			c1, e := dialTLSTraced(ctx, &nd, network, addr, tlsc)
 			if connSpy {
 				pod.sampleConn = c1
 			}
@@ -342,7 +569,10 @@ func (cfg Config) scaffoldHttpClientWith(max int, connSpy bool, pod *P0d) *http.
 	//see https://stackoverflow.com/questions/57683132/turning-off-connection-pool-for-go-http-client
 	if cfg.Exec.Concurrency == UNLIMITED {
 		t.DisableKeepAlives = true
-		logv(Yellow("transport connection pool disabled for http/1.1"))
+		//without a run there are no reporters to tell, same as logf.
+		if pod != nil {
+			pod.logf("%v", Yellow("transport connection pool disabled for http/1.1"))
+		}
 	}
 
 	if cfg.Exec.HttpVersion == http20 {
@@ -367,6 +597,5 @@ func (cfg Config) byteCount(b int64) string {
 }
 
 func (cfg Config) panic(msg string) {
-	logv(Red(msg))
-	os.Exit(-1)
+	panic(ConfigError{msg})
 }
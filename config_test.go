@@ -12,21 +12,21 @@ func TestGetRemotePort(t *testing.T) {
 	}
 	_ = cfg.validate()
 
-	if cfg.getRemotePort() != 8080 {
+	if cfg.Req.getRemotePort() != 8080 {
 		t.Error("invalid remote port")
 	}
 
 	cfg.Req.Url = "http://localhost/blah"
 	_ = cfg.validate()
 
-	if cfg.getRemotePort() != 80 {
+	if cfg.Req.getRemotePort() != 80 {
 		t.Error("invalid remote port")
 	}
 
 	cfg.Req.Url = "https://localhost/blah"
 	_ = cfg.validate()
 
-	if cfg.getRemotePort() != 443 {
+	if cfg.Req.getRemotePort() != 443 {
 		t.Error("invalid remote port")
 	}
 }
@@ -229,23 +229,126 @@ func TestByteCount(t *testing.T) {
 
 func TestSetDefaultFormDataContentType(t *testing.T) {
 	cfg := Config{}
-	cfg.setContentType(multipartFormdata, false)
+	cfg.Req.setContentType(multipartFormdata, false)
 
-	if !cfg.hasContentType(multipartFormdata) {
+	if !cfg.Req.hasContentType(multipartFormdata) {
 		t.Errorf("should be multipart/form-data")
 	}
 
 	//should not overwrite
-	cfg.setContentType(applicationJson, false)
+	cfg.Req.setContentType(applicationJson, false)
 
-	if !cfg.hasContentType(multipartFormdata) {
+	if !cfg.Req.hasContentType(multipartFormdata) {
 		t.Errorf("should be multipart/form-data")
 	}
 
 	//now it should
-	cfg.setContentType(applicationJson, true)
+	cfg.Req.setContentType(applicationJson, true)
 
-	if !cfg.hasContentType(applicationJson) {
+	if !cfg.Req.hasContentType(applicationJson) {
 		t.Errorf("should be application/json")
 	}
 }
+
+func TestReqsConfigValidate(t *testing.T) {
+	cfg := loadConfigFromFile("./examples/config_mix.yml")
+	cfg.validate()
+
+	if len(cfg.Reqs) != 3 {
+		t.Errorf("should have 3 request templates, got %v", len(cfg.Reqs))
+	}
+	if cfg.Req.Name != "list items" || cfg.Req.Url != "http://localhost:60083/mse6/get" {
+		t.Error("first request template should stand in for req")
+	}
+	if !cfg.Reqs[1].hasContentType(applicationJson) {
+		t.Error("post template should have default content type")
+	}
+	if cfg.Reqs[2].Method != "DELETE" || cfg.Reqs[2].Weight != 10 {
+		t.Error("delete template incorrectly parsed")
+	}
+
+	cfg2 := Config{
+		Reqs: []Req{
+			{Url: "http://localhost:8080/a"},
+			{Url: "localhost/b", Method: "post"},
+		},
+	}
+	cfg2.validate()
+	if cfg2.Reqs[0].Name != "req 1" || cfg2.Reqs[1].Name != "req 2" {
+		t.Error("default request template names incorrect")
+	}
+	if cfg2.Reqs[0].Weight != 1 || cfg2.Reqs[1].Weight != 1 {
+		t.Error("default request template weights incorrect")
+	}
+	if cfg2.Reqs[1].Method != "POST" || cfg2.Reqs[1].Url != "http://localhost/b" {
+		t.Error("request template not validated")
+	}
+}
+
+func TestPickReq(t *testing.T) {
+	cfg := Config{
+		Req: Req{Name: "single"},
+	}
+	if cfg.pickReq().Name != "single" {
+		t.Error("should pick req without templates")
+	}
+
+	cfg.Reqs = []Req{
+		{Name: "a", Weight: 70},
+		{Name: "b", Weight: 20},
+		{Name: "c", Weight: 10},
+	}
+	picked := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		picked[cfg.pickReq().Name]++
+	}
+	if picked["a"] < 6500 || picked["a"] > 7500 {
+		t.Errorf("weight a incorrect, got %v", picked["a"])
+	}
+	if picked["b"] < 1500 || picked["b"] > 2500 {
+		t.Errorf("weight b incorrect, got %v", picked["b"])
+	}
+	if picked["c"] < 500 || picked["c"] > 1500 {
+		t.Errorf("weight c incorrect, got %v", picked["c"])
+	}
+}
+
+type validateErrorTest struct {
+	name string
+	cfg  Config
+}
+
+func TestValidateError(t *testing.T) {
+	tests := []validateErrorTest{
+		{"short duration", Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{DurationSeconds: 2}}},
+		{"negative rate", Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{Rate: -1}}},
+		{"no url", Config{}},
+		{"rate with concurrency stages", Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{Rate: 10,
+			Stages: []Stage{{DurationSeconds: 10, Concurrency: 5}}}}},
+		{"bad histogram", Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{Histogram: Histogram{Type: "x"}}}},
+	}
+	for _, tt := range tests {
+		e := tt.cfg.Validate()
+		if _, ok := e.(ConfigError); !ok {
+			t.Errorf("%s should return a config error, got %v", tt.name, e)
+		}
+	}
+
+	cfg := Config{Req: Req{Url: "http://localhost/"}}
+	if e := cfg.Validate(); e != nil {
+		t.Errorf("valid config should not error, got %v", e)
+	}
+}
+
+func TestLoadConfigError(t *testing.T) {
+	if _, e := LoadConfig("./examples/nope.yml"); e == nil {
+		t.Error("missing config file should error")
+	}
+	if _, e := NewP0dFromConfigFile("./examples/nope.yml", ""); e == nil {
+		t.Error("missing config file should error")
+	}
+	cfg, e := LoadConfig("./examples/config_get.yml")
+	if e != nil || cfg.File != "./examples/config_get.yml" {
+		t.Errorf("should load config, got %v", e)
+	}
+}
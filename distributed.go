@@ -0,0 +1,614 @@
+package p0d
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	. "github.com/logrusorgru/aurora"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const agentRunPath = "/run"
+const agentStopPath = "/stop"
+const agentStartLead = time.Second * 2
+const agentStopTimeout = time.Second * 3
+const applicationNdjson = "application/x-ndjson"
+const authorization = "Authorization"
+const bearer = "Bearer "
+const agentStats = "stats"
+
+type agentJob struct {
+	ID     string
+	Agent  int
+	Start  time.Time
+	Config []byte
+	Files  map[string][]byte
+	Share  agentShare
+}
+
+type agentShare struct {
+	Concurrency int
+	Rate        int
+	Stages      []Stage
+}
+
+type agentRun struct {
+	ID   string
+	Time Time
+	OS   struct {
+		MaxOpenConns int
+	}
+	ReqStats    *ReqStats
+	Interrupted bool
+	AbortReason string
+}
+
+type agentMsg struct {
+	agent int
+	typ   string
+	data  json.RawMessage
+	done  bool
+	err   error
+}
+
+type agentStream struct {
+	enc     *json.Encoder
+	f       http.Flusher
+	err     error
+	buckets int
+}
+
+type Agent struct {
+	Listen string
+	Token  string
+	//Output sets up the reporters of each run, the console unless set.
+	Output func(p *P0d)
+
+	lock sync.Mutex
+	busy bool
+	pod  *P0d
+}
+
+func NewAgent(listen string, token string) *Agent {
+	return &Agent{Listen: listen, Token: token}
+}
+
+func (a *Agent) ListenAndServe() error {
+	l, e := net.Listen("tcp", a.Listen)
+	if e != nil {
+		return e
+	}
+	//between runs we report through an idle run, so the agent prints the same way its runs do.
+	idle := a.newP0d(Config{})
+	//anyone who can reach us could otherwise use us to send load anywhere.
+	if a.Token == N {
+		a.Token = uuid.NewString()
+		idle.logf("agent token %s, pass it to the coordinator with -token", Yellow(a.Token))
+	}
+	idle.logf("agent waiting for runs on %s", Yellow(l.Addr().String()))
+	return http.Serve(l, a.handler())
+}
+
+func (a *Agent) newP0d(cfg Config) *P0d {
+	_, ul := getUlimit()
+	p := NewP0d(cfg, ul, N, cfg.Exec.DurationSeconds, make(chan os.Signal, 1))
+	p.AddReporter(NewConsoleReporter(p))
+	if a.Output != nil {
+		a.Output(p)
+	}
+	return p
+}
+
+func (a *Agent) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(agentRunPath, a.authorize(a.serveRun))
+	mux.HandleFunc(agentStopPath, a.authorize(a.serveStop))
+	return mux
+}
+
+func (a *Agent) authorize(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := strings.TrimPrefix(r.Header.Get(authorization), bearer)
+		if a.Token == N || subtle.ConstantTimeCompare([]byte(t), []byte(a.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func agentReq(url string, token string, body io.Reader) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, url, body)
+	req.Header.Set(ct, applicationJson)
+	req.Header.Set(authorization, bearer+token)
+	return req
+}
+
+func (a *Agent) serveRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var job agentJob
+	if e := json.NewDecoder(r.Body).Decode(&job); e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.lock.Lock()
+	if a.busy {
+		a.lock.Unlock()
+		http.Error(w, "agent is busy with another run", http.StatusConflict)
+		return
+	}
+	a.busy = true
+	a.lock.Unlock()
+	defer func() {
+		a.lock.Lock()
+		a.busy = false
+		a.pod = nil
+		a.lock.Unlock()
+	}()
+
+	dir, e := os.MkdirTemp("", "p0d-agent-*")
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	cfg, e := job.config(dir)
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+	p := a.newP0d(*cfg)
+
+	w.Header().Set(ct, applicationNdjson)
+	w.WriteHeader(http.StatusOK)
+	p.AddReporter(newAgentStream(w))
+	a.lock.Lock()
+	a.pod = p
+	a.lock.Unlock()
+
+	//all agents start at the same time, whenever the job arrived.
+	select {
+	case <-time.After(time.Until(job.Start)):
+	case <-r.Context().Done():
+		return
+	}
+	//the coordinator going away stops the run, same as CTRL+C would.
+	p.Race(r.Context())
+}
+
+func (a *Agent) serveStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.lock.Lock()
+	p := a.pod
+	a.lock.Unlock()
+	if p == nil {
+		http.Error(w, "agent has no run in progress", http.StatusConflict)
+		return
+	}
+	//the coordinator decides whether its run was interrupted, we just finish early.
+	p.stopRun()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (job agentJob) config(dir string) (*Config, error) {
+	cfg, e := parseConfig(job.Config)
+	if e != nil {
+		return nil, e
+	}
+	if e = cfg.localFiles(job.Files, dir); e != nil {
+		return nil, e
+	}
+	//agents may share a machine, each writes its own logs and none of them binds a metrics port.
+	cfg.Exec.MetricsListen = N
+	cfg.Exec.RequestLog = agentFile(cfg.Exec.RequestLog, job.Agent)
+	cfg.Exec.HdrLog = agentFile(cfg.Exec.HdrLog, job.Agent)
+	cfg.Exec.Concurrency = job.Share.Concurrency
+	cfg.Exec.Rate = job.Share.Rate
+	cfg.Exec.Stages = job.Share.Stages
+	//only hdr histograms can be merged exactly by the coordinator.
+	cfg.Exec.Histogram.Type = HistogramHdr
+	return cfg, cfg.Validate()
+}
+
+func agentFile(f string, agent int) string {
+	if f == N {
+		return N
+	}
+	ext := filepath.Ext(f)
+	return fmt.Sprintf("%s.agent%d%s", strings.TrimSuffix(f, ext), agent, ext)
+}
+
+func (cfg *Config) eachReq(f func(r *Req)) {
+	f(&cfg.Req)
+	for i := range cfg.Reqs {
+		f(&cfg.Reqs[i])
+	}
+	for i := range cfg.Scenario.Steps {
+		f(&cfg.Scenario.Steps[i].Req)
+	}
+}
+
+func (cfg *Config) eachFile(f func(path *string)) {
+	cfg.eachReq(func(r *Req) {
+		if r.Feeder != nil {
+			f(&r.Feeder.File)
+		}
+		for _, fd := range r.FormData {
+			for k, v := range fd {
+				if strings.HasPrefix(k, AT) {
+					f(&v)
+					fd[k] = v
+				}
+			}
+		}
+	})
+}
+
+func (cfg *Config) agentFiles() (map[string][]byte, error) {
+	//agents don't share our file system, so feeders and form data files travel with the job.
+	files := make(map[string][]byte)
+	var err error
+	cfg.eachFile(func(path *string) {
+		if _, ok := files[*path]; ok || err != nil {
+			return
+		}
+		files[*path], err = os.ReadFile(*path)
+	})
+	return files, err
+}
+
+func (cfg *Config) localFiles(files map[string][]byte, dir string) error {
+	//each file gets its own directory, so form data still sends the original file name.
+	local := make(map[string]string, len(files))
+	i := 0
+	for path, b := range files {
+		d := filepath.Join(dir, strconv.Itoa(i))
+		i++
+		if e := os.Mkdir(d, 0700); e != nil {
+			return e
+		}
+		l := filepath.Join(d, filepath.Base(path))
+		if e := os.WriteFile(l, b, 0600); e != nil {
+			return e
+		}
+		local[path] = l
+	}
+	cfg.eachFile(func(path *string) {
+		if l, ok := local[*path]; ok {
+			*path = l
+		}
+	})
+	return nil
+}
+
+func newAgentStream(w http.ResponseWriter) *agentStream {
+	s := &agentStream{enc: json.NewEncoder(w)}
+	s.f, _ = w.(http.Flusher)
+	return s
+}
+
+func (s *agentStream) OnStart(cfg Config) {}
+
+func (s *agentStream) OnLog(msg string) {}
+
+func (s *agentStream) OnPhase(phase TimerPhase) {}
+
+func (s *agentStream) OnAttempt(ra ReqAtmpt) {}
+
+func (s *agentStream) OnSnapshot(rs *ReqStats) {
+	//totals so far, once a second. the series only grows, it goes with the summary instead.
+	if len(newBuckets(rs, &s.buckets)) == 0 {
+		return
+	}
+	c := *rs
+	c.Series = nil
+	s.record(agentStats, time.Now(), &c)
+}
+
+func (s *agentStream) OnFinish(p *P0d) {
+	s.record(RecordSummary, p.Time.Stop, p)
+}
+
+func (s *agentStream) record(typ string, now time.Time, data any) {
+	if s.err != nil {
+		return
+	}
+	s.err = s.enc.Encode(Record{Type: typ, Time: now, Data: data})
+	if s.f != nil {
+		s.f.Flush()
+	}
+}
+
+func (cfg Config) splitShares(n int) []agentShare {
+	if cfg.Exec.Concurrency < n {
+		cfg.panic(fmt.Sprintf("concurrency %d cannot be split across %d agents", cfg.Exec.Concurrency, n))
+	}
+	if cfg.isRateMode() && cfg.Exec.Rate < n {
+		cfg.panic(fmt.Sprintf("rate %d cannot be split across %d agents", cfg.Exec.Rate, n))
+	}
+	split := func(v int, i int) int {
+		//the first agents take the remainder
+		s := v / n
+		if i < v%n {
+			s++
+		}
+		return s
+	}
+	shares := make([]agentShare, n)
+	for i := range shares {
+		sh := agentShare{
+			Concurrency: split(cfg.Exec.Concurrency, i),
+			Rate:        split(cfg.Exec.Rate, i),
+		}
+		for _, st := range cfg.Exec.Stages {
+			st.Concurrency = split(st.Concurrency, i)
+			st.Rate = split(st.Rate, i)
+			sh.Stages = append(sh.Stages, st)
+		}
+		shares[i] = sh
+	}
+	return shares
+}
+
+func agentUrl(a string) string {
+	a = strings.TrimSuffix(strings.TrimSpace(a), "/")
+	if !strings.Contains(a, "://") {
+		a = "http://" + a
+	}
+	return a
+}
+
+func (p *P0d) Coordinate(agents []string, token string) (err error) {
+	defer recoverConfigError(&err)
+	if len(agents) == 0 {
+		p.Config.panic("coordinate needs at least one agent")
+	}
+	if token == N {
+		p.Config.panic("coordinate needs the agents' token")
+	}
+	if p.Config.File == N {
+		p.Config.panic("coordinate needs a config file to send to its agents")
+	}
+	yml, e := os.ReadFile(p.Config.File)
+	if e != nil {
+		p.Config.panic(fmt.Sprintf("unable to read config %s: %s", p.Config.File, e.Error()))
+	}
+	raw, e := parseConfig(yml)
+	if e != nil {
+		p.Config.panic(fmt.Sprintf("unable to parse config %s: %s", p.Config.File, e.Error()))
+	}
+	files, e := raw.agentFiles()
+	if e != nil {
+		p.Config.panic(fmt.Sprintf("unable to read files for agents: %s", e.Error()))
+	}
+	shares := p.Config.splitShares(len(agents))
+	p.Config.Exec.Histogram.Type = HistogramHdr
+	p.ReqStats = NewReqStats(p.Config)
+	//the agents send the load, what our own network can do doesn't matter.
+	p.Config.Exec.SkipInetTest = true
+	p.OS.LimitRAMBytes = getRAMBytes()
+
+	p.initOutFile()
+	p.onStart()
+	if p.Config.Exec.MetricsListen != N {
+		p.logf("%v", Yellow("metricsListen is not served in coordinated runs, agents report to the coordinator instead"))
+	}
+
+	start := time.Now().Add(agentStartLead)
+	p.Time.Start = start
+	client := &http.Client{}
+	msgs := make(chan agentMsg, len(agents)*8)
+	for i, a := range agents {
+		agents[i] = agentUrl(a)
+		if p.Config.isRateMode() {
+			p.logf("agent %s runs rate %s%s concurrency %s", Yellow(agents[i]), Yellow(FGroup(int64(shares[i].Rate))),
+				Yellow(perSecondMsg), Yellow(FGroup(int64(shares[i].Concurrency))))
+		} else {
+			p.logf("agent %s runs concurrency %s", Yellow(agents[i]), Yellow(FGroup(int64(shares[i].Concurrency))))
+		}
+		job := agentJob{ID: p.ID, Agent: i, Start: start, Config: yml, Files: files, Share: shares[i]}
+		go runAgent(client, i, agents[i], token, job, msgs)
+	}
+	p.setTimerPhase(Main)
+
+	runs := make([]*agentRun, len(agents))
+	errs := make([]error, len(agents))
+	live := make([]*ReqStats, len(agents))
+	running := len(agents)
+	stopping := p.stopping
+Coordinate:
+	for {
+		select {
+		case <-stopping:
+			//a closed channel fires forever, we only need to hear it once.
+			stopping = nil
+			p.logf("%v", Yellow("stopping agents"))
+			p.stopAgents(agents, token)
+		case <-p.interrupt:
+			//agents still send their summaries after stopping, so we keep listening.
+			if !p.Interrupted {
+				p.Interrupted = true
+				p.logf("%v", Yellow("interrupted, stopping agents"))
+				p.stopAgents(agents, token)
+			}
+		case m := <-msgs:
+			if m.done {
+				if m.err != nil {
+					errs[m.agent] = m.err
+				}
+				running--
+				if running == 0 {
+					break Coordinate
+				}
+				continue
+			}
+			switch m.typ {
+			case agentStats:
+				s := NewReqStats(p.Config)
+				if json.Unmarshal(m.data, s) != nil {
+					continue
+				}
+				live[m.agent] = s
+				p.mergeLiveStats(live)
+				p.onSnapshot()
+			case RecordSummary:
+				var r agentRun
+				if e := json.Unmarshal(m.data, &r); e != nil {
+					errs[m.agent] = e
+					continue
+				}
+				runs[m.agent] = &r
+			}
+		}
+	}
+
+	p.setTimerPhase(Done)
+	p.Time.Stop = time.Now()
+	//the summaries are exact, they replace what we pieced together live.
+	p.ReqStats = NewReqStats(p.Config)
+	p.mergeAgentRuns(agents, runs, errs)
+	p.evalThresholds()
+	p.logCoordinatedSummary(len(agents))
+	p.onSnapshot()
+	p.onFinish()
+	p.writeReport()
+	p.logf("%v", Cyan("exiting"))
+	return nil
+}
+
+func (p *P0d) mergeLiveStats(live []*ReqStats) {
+	//agents send their totals so far, so the live view is rebuilt from the latest of each.
+	s := NewReqStats(p.Config)
+	for _, l := range live {
+		if l == nil || s.merge(l) != nil {
+			continue
+		}
+		s.CurReqAtmptsPSec += l.CurReqAtmptsPSec
+		s.CurBytesReadPSec += l.CurBytesReadPSec
+		s.CurBytesWrittenPSec += l.CurBytesWrittenPSec
+	}
+	p.ReqStats = s
+}
+
+func runAgent(client *http.Client, i int, url string, token string, job agentJob, msgs chan<- agentMsg) {
+	b, _ := json.Marshal(job)
+	res, e := client.Do(agentReq(url+agentRunPath, token, bytes.NewReader(b)))
+	if e != nil {
+		msgs <- agentMsg{agent: i, done: true, err: e}
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		msgs <- agentMsg{agent: i, done: true,
+			err: fmt.Errorf("HTTP %d %s", res.StatusCode, strings.TrimSpace(string(body)))}
+		return
+	}
+	d := json.NewDecoder(res.Body)
+	for {
+		var r struct {
+			Type string
+			Data json.RawMessage
+		}
+		if e := d.Decode(&r); e != nil {
+			if e == io.EOF {
+				e = nil
+			}
+			msgs <- agentMsg{agent: i, done: true, err: e}
+			return
+		}
+		msgs <- agentMsg{agent: i, typ: r.Type, data: r.Data}
+	}
+}
+
+func (p *P0d) stopAgents(agents []string, token string) {
+	client := &http.Client{Timeout: agentStopTimeout}
+	for _, a := range agents {
+		res, e := client.Do(agentReq(a+agentStopPath, token, nil))
+		if e != nil {
+			p.logf("%v", Red(fmt.Sprintf("unable to stop agent %s: %s", a, e.Error())))
+			continue
+		}
+		res.Body.Close()
+	}
+}
+
+func (p *P0d) mergeAgentRuns(agents []string, runs []*agentRun, errs []error) {
+	var start, stop time.Time
+	for i, r := range runs {
+		if r == nil || r.ReqStats == nil {
+			e := errs[i]
+			if e == nil {
+				e = fmt.Errorf("no %s record received", RecordSummary)
+			}
+			msg := fmt.Sprintf("agent %s failed: %s", agents[i], e.Error())
+			p.logf("%v", Red(msg))
+			if p.AbortReason == N {
+				p.AbortReason = msg
+			}
+			continue
+		}
+		if e := p.ReqStats.merge(r.ReqStats); e != nil {
+			msg := fmt.Sprintf("unable to merge stats from agent %s: %s", agents[i], e.Error())
+			p.logf("%v", Red(msg))
+			if p.AbortReason == N {
+				p.AbortReason = msg
+			}
+			continue
+		}
+		p.OS.MaxOpenConns += r.OS.MaxOpenConns
+		if start.IsZero() || r.Time.Start.Before(start) {
+			start = r.Time.Start
+		}
+		if r.Time.Stop.After(stop) {
+			stop = r.Time.Stop
+		}
+		if r.Interrupted {
+			p.Interrupted = true
+		}
+		if r.AbortReason != N && p.AbortReason == N {
+			p.AbortReason = fmt.Sprintf("agent %s: %s", agents[i], r.AbortReason)
+		}
+	}
+	if !start.IsZero() {
+		p.Time.Start = start
+		p.Time.Stop = stop
+	}
+}
+
+const coordinatedSummaryMsg = "  - agents: %s HTTP req: %s roundtrip throughput mean: %s%s max: %s%s latency pct50: %s pct90: %s pct99: %s pct99.9: %s"
+
+func (p *P0d) logCoordinatedSummary(agents int) {
+	s := p.ReqStats
+	p.logf("%v", Cyan(fmt.Sprintf(coordinatedSummaryMsg,
+		FGroup(int64(agents)),
+		FGroup(s.ReqAtmpts),
+		FGroup(s.MeanReqAtmptsPSec),
+		perSecondMsg,
+		FGroup(s.MaxReqAtmptsPSec),
+		perSecondMsg,
+		fmtLatency(s.ElpsdAtmptLatencyNsQuantiles, 0.5),
+		fmtLatency(s.ElpsdAtmptLatencyNsQuantiles, 0.9),
+		fmtLatency(s.ElpsdAtmptLatencyNsQuantiles, 0.99),
+		fmtLatency(s.ElpsdAtmptLatencyNsQuantiles, 0.999))))
+	p.logf("%v", Cyan(fmt.Sprintf("  - matching HTTP response codes: %s (%.2f%%) transport errors: %s (%.2f%%)",
+		FGroup(int64(s.SumMatchingResponseCodes)), s.PctMatchingResponseCodes,
+		FGroup(int64(s.SumErrors)), s.PctErrors)))
+}
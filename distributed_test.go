@@ -0,0 +1,241 @@
+package p0d
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type splitSharesTest struct {
+	n           int
+	concurrency int
+	rate        int
+	stages      []Stage
+	want        []agentShare
+}
+
+func TestSplitShares(t *testing.T) {
+	tests := []splitSharesTest{
+		{n: 1, concurrency: 8, want: []agentShare{{Concurrency: 8}}},
+		{n: 3, concurrency: 10, want: []agentShare{{Concurrency: 4}, {Concurrency: 3}, {Concurrency: 3}}},
+		{n: 2, concurrency: 4, rate: 101, want: []agentShare{{Concurrency: 2, Rate: 51}, {Concurrency: 2, Rate: 50}}},
+		{n: 2, concurrency: 10, stages: []Stage{{Name: "a", DurationSeconds: 5, Concurrency: 10}, {Name: "b", DurationSeconds: 5, Concurrency: 3}},
+			want: []agentShare{
+				{Concurrency: 5, Stages: []Stage{{Name: "a", DurationSeconds: 5, Concurrency: 5}, {Name: "b", DurationSeconds: 5, Concurrency: 2}}},
+				{Concurrency: 5, Stages: []Stage{{Name: "a", DurationSeconds: 5, Concurrency: 5}, {Name: "b", DurationSeconds: 5, Concurrency: 1}}},
+			}},
+	}
+	for _, tt := range tests {
+		cfg := Config{Exec: Exec{Concurrency: tt.concurrency, Rate: tt.rate, Stages: tt.stages}}
+		got := cfg.splitShares(tt.n)
+		g, _ := json.Marshal(got)
+		w, _ := json.Marshal(tt.want)
+		if string(g) != string(w) {
+			t.Errorf("split %d ways should be %s, got %s", tt.n, w, g)
+		}
+	}
+}
+
+func TestAgentUrl(t *testing.T) {
+	if agentUrl("a:7000") != "http://a:7000" || agentUrl(" https://b:7000/ ") != "https://b:7000" {
+		t.Error("agent url incorrect")
+	}
+}
+
+func TestWelfordJSON(t *testing.T) {
+	w := NewWelford()
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		w.Add(v)
+	}
+	j, _ := json.Marshal(w)
+	var r Welford
+	if e := json.Unmarshal(j, &r); e != nil {
+		t.Fatalf("should restore welford, got %v", e)
+	}
+	if r.Mean() != w.Mean() || math.Abs(r.Stddev()-w.Stddev()) > 1e-9 {
+		t.Errorf("restored welford should match, want %v %v got %v %v", w.Mean(), w.Stddev(), r.Mean(), r.Stddev())
+	}
+}
+
+func TestReqStatsMerge(t *testing.T) {
+	cfg := Config{Exec: Exec{Histogram: Histogram{Type: HistogramHdr, Precision: 3}}, Res: Res{Code: 200}}
+	start := time.Now()
+	all, a, b := NewReqStats(cfg), NewReqStats(cfg), NewReqStats(cfg)
+	for _, s := range []*ReqStats{all, a, b} {
+		s.setStart(start)
+		s.openBucket(start)
+	}
+	for i := 1; i <= 1000; i++ {
+		ra := ReqAtmpt{ElpsdNs: time.Duration(i) * time.Millisecond, ResCode: 200, ResBytes: 10}
+		if i%100 == 0 {
+			ra.ResCode = 0
+			ra.ResErr = "timeout"
+		}
+		now := start.Add(time.Second)
+		all.update(ra, now, cfg)
+		if i%2 == 0 {
+			a.update(ra, now, cfg)
+		} else {
+			b.update(ra, now, cfg)
+		}
+	}
+	for _, s := range []*ReqStats{a, b} {
+		s.closeBucket(start.Add(time.Second), "main", 1)
+	}
+
+	//agents send their stats as json, merge what comes out the other end.
+	m := NewReqStats(cfg)
+	for _, s := range []*ReqStats{a, b} {
+		j, _ := json.Marshal(s)
+		var r ReqStats
+		if e := json.Unmarshal(j, &r); e != nil {
+			t.Fatalf("should restore req stats, got %v", e)
+		}
+		if e := m.merge(&r); e != nil {
+			t.Fatalf("should merge req stats, got %v", e)
+		}
+	}
+
+	if m.ReqAtmpts != all.ReqAtmpts || m.SumErrors != all.SumErrors || m.ErrorTypes["timeout"] != 10 ||
+		m.SumBytesRead != all.SumBytesRead || m.PctErrors != all.PctErrors || m.MeanReqAtmptsPSec != all.MeanReqAtmptsPSec {
+		t.Errorf("merged counts should match a single run, got %d reqs %d errors", m.ReqAtmpts, m.SumErrors)
+	}
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		if m.ElpsdAtmptLatencyNsQuantiles.Quantile(q) != all.ElpsdAtmptLatencyNsQuantiles.Quantile(q) {
+			t.Errorf("merged quantile %v should match a single run", q)
+		}
+	}
+	if math.Abs(m.ElpsdAtmptLatencyNs.Mean()-all.ElpsdAtmptLatencyNs.Mean()) > 1 {
+		t.Errorf("merged mean should match a single run, want %v got %v", all.ElpsdAtmptLatencyNs.Mean(), m.ElpsdAtmptLatencyNs.Mean())
+	}
+	if len(m.Series) != 1 || m.Series[0].ReqAtmpts != 1000 || m.Series[0].OpenConns != 2 {
+		t.Errorf("series buckets should merge by start, got %v", m.Series)
+	}
+
+	if e := NewReqStats(Config{}).merge(a); e == nil {
+		t.Error("tdigest quantiles should not merge")
+	}
+}
+
+func TestCoordinate(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	agents := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		a := httptest.NewServer(NewAgent(N, "secret").handler())
+		defer a.Close()
+		agents = append(agents, a.URL)
+	}
+
+	f, _ := os.CreateTemp("", "p0d-coordinate-*.yml")
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, "exec:\n  durationSeconds: 3\n  concurrency: 4\n  skipInetTest: true\nreq:\n  url: %s\n", svr.URL)
+	f.Close()
+
+	p := NewP0dFromFile(f.Name(), "testoutput_coordinate.json")
+	defer os.Remove(p.Output)
+	p.Coordinate(agents, "secret")
+
+	if p.AbortReason != N || p.Interrupted {
+		t.Errorf("coordinated run should finish, got %s", p.AbortReason)
+	}
+	if p.ReqStats.ReqAtmpts == 0 || p.ReqStats.SumErrors > 0 || len(p.ReqStats.Series) == 0 {
+		t.Errorf("coordinated run should merge agent stats, got %d reqs %d errors", p.ReqStats.ReqAtmpts, p.ReqStats.SumErrors)
+	}
+	if p.ReqStats.ElpsdAtmptLatencyNsQuantiles.Quantile(0.5) <= 0 {
+		t.Error("coordinated run should merge latencies")
+	}
+	if _, e := loadRun(p.Output); e != nil {
+		t.Errorf("coordinated output should load for reports, got %v", e)
+	}
+
+	//agents are free again after a run
+	res, _ := http.DefaultClient.Do(agentReq(agents[0]+agentStopPath, "secret", nil))
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("idle agent should have nothing to stop, got %d", res.StatusCode)
+	}
+}
+
+func TestAgentToken(t *testing.T) {
+	a := httptest.NewServer(NewAgent(N, "secret").handler())
+	defer a.Close()
+
+	for _, tok := range []string{N, "nope"} {
+		for _, path := range []string{agentRunPath, agentStopPath} {
+			res, _ := http.DefaultClient.Do(agentReq(a.URL+path, tok, strings.NewReader("{}")))
+			if res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%s with token '%s' should be unauthorized, got %d", path, tok, res.StatusCode)
+			}
+		}
+	}
+
+	//an agent without a token takes no runs at all.
+	b := httptest.NewServer(NewAgent(N, N).handler())
+	defer b.Close()
+	res, _ := http.DefaultClient.Do(agentReq(b.URL+agentStopPath, N, nil))
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("agent without a token should refuse, got %d", res.StatusCode)
+	}
+}
+
+func TestMergeSeriesByStart(t *testing.T) {
+	start := time.Now()
+	bucket := func(offset int, reqs int64) *Bucket {
+		return &Bucket{Start: start.Add(time.Duration(offset)*time.Second + time.Millisecond*7), ElpsdNs: time.Second,
+			ReqAtmpts: reqs}
+	}
+	s := &ReqStats{Series: []*Bucket{bucket(0, 10), bucket(1, 10), bucket(2, 10)}}
+	s.Series[0].Start = start
+
+	//this run started a second late and missed its last bucket.
+	s.mergeSeries([]*Bucket{bucket(1, 5), bucket(3, 5)})
+
+	want := []int64{10, 15, 10, 5}
+	if len(s.Series) != len(want) {
+		t.Fatalf("want %d buckets got %d", len(want), len(s.Series))
+	}
+	for i, w := range want {
+		if s.Series[i].ReqAtmpts != w || !s.Series[i].Start.Before(start.Add(time.Duration(i+1)*time.Second)) {
+			t.Errorf("bucket %d should have %d reqs, got %d", i, w, s.Series[i].ReqAtmpts)
+		}
+	}
+}
+
+func TestAgentJobConfig(t *testing.T) {
+	yml := []byte("exec:\n  metricsListen: :9090\n  requestLog: out/requests.csv\n  hdrLog: latency.hlog\n" +
+		"req:\n  url: http://localhost/?user={{.user}}\n  feeder:\n    file: elsewhere/users.csv\n")
+	raw, _ := parseConfig(yml)
+	users, _ := os.ReadFile("./examples/users.csv")
+	raw.Req.Feeder.File = "./examples/users.csv"
+	files, e := raw.agentFiles()
+	if e != nil || len(files) != 1 {
+		t.Fatalf("should read the feeder for agents, got %v %v", len(files), e)
+	}
+
+	//the agent has no such file, it gets the coordinator's copy.
+	job := agentJob{Agent: 1, Config: yml, Files: map[string][]byte{"elsewhere/users.csv": users},
+		Share: agentShare{Concurrency: 1}}
+	dir := t.TempDir()
+	cfg, e := job.config(dir)
+	if e != nil {
+		t.Fatalf("agent config should be valid, got %v", e)
+	}
+	if !strings.HasPrefix(cfg.Req.Feeder.File, dir) || len(cfg.Req.Feeder.rows) != 4 {
+		t.Errorf("feeder should be read from the shipped copy, got %s", cfg.Req.Feeder.File)
+	}
+	if cfg.Exec.MetricsListen != N {
+		t.Error("agents should not bind the metrics port")
+	}
+	if cfg.Exec.RequestLog != "out/requests.agent1.csv" || cfg.Exec.HdrLog != "latency.agent1.hlog" {
+		t.Errorf("agent logs should be suffixed, got %s %s", cfg.Exec.RequestLog, cfg.Exec.HdrLog)
+	}
+}
@@ -0,0 +1,222 @@
+package p0d
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	FeederSequence = "sequence"
+	FeederRandom   = "random"
+	FeederCircular = "circular"
+)
+
+const (
+	FeederShared      = "shared"
+	FeederPartitioned = "partitioned"
+)
+
+type Feeder struct {
+	File    string
+	Mode    string
+	Sharing string
+
+	rows     []map[string]string
+	cursor   int64
+	cursors  []int64
+	slots    int
+	drained  []int32
+	ndrained int64
+}
+
+func (cfg *Config) validateFeeder(r *Req) {
+	f := r.Feeder
+	if f == nil {
+		return
+	}
+	if f.File == "" {
+		cfg.panic(fmt.Sprintf("%s feeder file not specified", r.Name))
+	}
+
+	f.Mode = strings.ToLower(f.Mode)
+	if f.Mode == "" {
+		f.Mode = FeederSequence
+	}
+	if f.Mode != FeederSequence && f.Mode != FeederRandom && f.Mode != FeederCircular {
+		cfg.panic(fmt.Sprintf("feeder mode must be one of %s, %s, %s, yours: %s",
+			FeederSequence, FeederRandom, FeederCircular, f.Mode))
+	}
+
+	f.Sharing = strings.ToLower(f.Sharing)
+	if f.Sharing == "" {
+		f.Sharing = FeederShared
+	}
+	if f.Sharing != FeederShared && f.Sharing != FeederPartitioned {
+		cfg.panic(fmt.Sprintf("feeder sharing must be one of %s, %s, yours: %s",
+			FeederShared, FeederPartitioned, f.Sharing))
+	}
+
+	var e error
+	f.rows, e = loadFeederRows(f.File)
+	if e != nil {
+		cfg.panic(fmt.Sprintf("unable to read feeder file %s: %s", f.File, e.Error()))
+	}
+	if len(f.rows) == 0 {
+		cfg.panic(fmt.Sprintf("feeder file %s has no rows", f.File))
+	}
+
+	if f.Sharing == FeederPartitioned {
+		//each connection slot gets every nth row, so slots never send the same row.
+		f.slots = cfg.Exec.Concurrency
+		if len(f.rows) < f.slots {
+			cfg.panic(fmt.Sprintf("partitioned feeder needs at least %d rows for %d connections, yours: %d",
+				f.slots, f.slots, len(f.rows)))
+		}
+		f.cursors = make([]int64, f.slots)
+		f.drained = make([]int32, f.slots)
+	}
+}
+
+func loadFeederRows(file string) ([]map[string]string, error) {
+	fh, e := os.Open(file)
+	if e != nil {
+		return nil, e
+	}
+	defer fh.Close()
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return loadCsvRows(fh)
+	case ".jsonl", ".ndjson":
+		return loadJsonlRows(fh)
+	default:
+		return nil, fmt.Errorf("unknown feeder format, use .csv or .jsonl")
+	}
+}
+
+func loadCsvRows(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	recs, e := cr.ReadAll()
+	if e != nil {
+		return nil, e
+	}
+	if len(recs) == 0 {
+		return nil, nil
+	}
+
+	//the first line names the columns
+	cols := recs[0]
+	rows := make([]map[string]string, 0, len(recs)-1)
+	for _, rec := range recs[1:] {
+		row := make(map[string]string, len(cols))
+		for j, c := range cols {
+			row[c] = rec[j]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func loadJsonlRows(r io.Reader) ([]map[string]string, error) {
+	rows := make([]map[string]string, 0)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		l := strings.TrimSpace(sc.Text())
+		if l == "" {
+			continue
+		}
+		var obj map[string]any
+		if e := json.Unmarshal([]byte(l), &obj); e != nil {
+			return nil, fmt.Errorf("line %d: %s", line, e.Error())
+		}
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			if s, ok := v.(string); ok {
+				row[k] = s
+			} else {
+				j, _ := json.Marshal(v)
+				row[k] = string(j)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, sc.Err()
+}
+
+func (f *Feeder) next(slot int) (map[string]string, bool) {
+	//shared feeders are one list for all connections, partitioned ones a list per slot.
+	offset, stride, n := 0, 1, len(f.rows)
+	cursor := &f.cursor
+	if f.Sharing == FeederPartitioned {
+		slot = slot % f.slots
+		offset, stride = slot, f.slots
+		n = (len(f.rows) - slot + f.slots - 1) / f.slots
+		cursor = &f.cursors[slot]
+	}
+
+	var k int
+	switch f.Mode {
+	case FeederRandom:
+		k = rand.Intn(n)
+	case FeederCircular:
+		k = int((atomic.AddInt64(cursor, 1) - 1) % int64(n))
+	default:
+		k = int(atomic.AddInt64(cursor, 1) - 1)
+		if k >= n {
+			return nil, false
+		}
+	}
+	return f.rows[offset+k*stride], true
+}
+
+func (r *Req) feed(slot int, vars map[string]string) (map[string]string, bool) {
+	if r.Feeder == nil {
+		return vars, true
+	}
+	row, ok := r.Feeder.next(slot)
+	if !ok {
+		return vars, false
+	}
+	if vars == nil {
+		vars = make(map[string]string, len(row))
+	}
+	for k, v := range row {
+		vars[k] = v
+	}
+	return vars, true
+}
+
+func (f *Feeder) exhaust(slot int) bool {
+	//a shared feeder is out for everyone, a partitioned one only once every slot has run dry.
+	if f.Sharing != FeederPartitioned {
+		return true
+	}
+	slot = slot % f.slots
+	if atomic.CompareAndSwapInt32(&f.drained[slot], 0, 1) {
+		return atomic.AddInt64(&f.ndrained, 1) == int64(f.slots)
+	}
+	return false
+}
+
+func (p *P0d) feederExhausted(f *Feeder, slot int) {
+	if !f.exhaust(slot) {
+		return
+	}
+	//don't block, one signal is enough to end the run.
+	select {
+	case p.exhausted <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,159 @@
+package p0d
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadFeederRows(t *testing.T) {
+	csv, e := loadFeederRows("./examples/users.csv")
+	if e != nil || len(csv) != 4 {
+		t.Errorf("should load 4 csv rows, got %v %v", len(csv), e)
+	}
+	if csv[1]["user"] != "bob" || csv[1]["term"] != "hats" {
+		t.Errorf("csv row incorrectly parsed, got %v", csv[1])
+	}
+
+	jsonl, e := loadFeederRows("./examples/searches.jsonl")
+	if e != nil || len(jsonl) != 3 {
+		t.Errorf("should load 3 jsonl rows, got %v %v", len(jsonl), e)
+	}
+	if jsonl[1]["user"] != "bob" || jsonl[1]["page"] != "2" {
+		t.Errorf("jsonl row incorrectly parsed, got %v", jsonl[1])
+	}
+
+	if _, e := loadFeederRows("./examples/config_get.yml"); e == nil {
+		t.Error("should not load unknown format")
+	}
+}
+
+func TestFeederConfigValidate(t *testing.T) {
+	cfg := loadConfigFromFile("./examples/config_feeder.yml")
+	cfg.validate()
+
+	f := cfg.Req.Feeder
+	if f == nil || len(f.rows) != 4 {
+		t.Error("feeder not loaded")
+	}
+	if f.Mode != FeederCircular || f.Sharing != FeederPartitioned || len(f.cursors) != 2 {
+		t.Error("feeder settings incorrectly parsed")
+	}
+
+	cfg2 := Config{
+		Req: Req{
+			Url:    "http://localhost:8080/",
+			Feeder: &Feeder{File: "./examples/users.csv"},
+		},
+	}
+	cfg2.validate()
+	if cfg2.Req.Feeder.Mode != FeederSequence || cfg2.Req.Feeder.Sharing != FeederShared {
+		t.Error("feeder defaults incorrect")
+	}
+}
+
+type feederTest struct {
+	n       string
+	mode    string
+	sharing string
+	slot    int
+	want    []string
+}
+
+func TestFeederNext(t *testing.T) {
+	rows := []map[string]string{{"v": "0"}, {"v": "1"}, {"v": "2"}, {"v": "3"}, {"v": "4"}}
+	tests := []feederTest{
+		{n: "shared sequence", mode: FeederSequence, sharing: FeederShared, slot: 0, want: []string{"0", "1", "2", "3", "4", ""}},
+		{n: "shared circular", mode: FeederCircular, sharing: FeederShared, slot: 1, want: []string{"0", "1", "2", "3", "4", "0"}},
+		{n: "partitioned sequence", mode: FeederSequence, sharing: FeederPartitioned, slot: 0, want: []string{"0", "2", "4", ""}},
+		{n: "partitioned circular", mode: FeederCircular, sharing: FeederPartitioned, slot: 1, want: []string{"1", "3", "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			f := Feeder{Mode: tt.mode, Sharing: tt.sharing, rows: rows, slots: 2, cursors: make([]int64, 2)}
+			for i, w := range tt.want {
+				row, ok := f.next(tt.slot)
+				if w == "" {
+					if ok {
+						t.Errorf("should be exhausted at %d", i)
+					}
+					continue
+				}
+				if !ok || row["v"] != w {
+					t.Errorf("row %d want %v got %v", i, w, row["v"])
+				}
+			}
+		})
+	}
+
+	f := Feeder{Mode: FeederRandom, Sharing: FeederPartitioned, rows: rows, slots: 2, cursors: make([]int64, 2)}
+	for i := 0; i < 20; i++ {
+		row, ok := f.next(1)
+		if !ok || (row["v"] != "1" && row["v"] != "3") {
+			t.Errorf("random partitioned feeder left its partition, got %v", row["v"])
+		}
+	}
+}
+
+func TestRaceWithSequenceFeeder(t *testing.T) {
+	var reqs int64
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("user") != "" && r.URL.Query().Get("term") != "" {
+			atomic.AddInt64(&reqs, 1)
+		}
+	}))
+	defer svr.Close()
+
+	p := NewP0dFromFile("./examples/config_feeder.yml", "")
+	p.Config.Req.Url = svr.URL + "/?user={{.user}}&term={{.term}}"
+	p.Config.Req.Feeder.Mode = FeederSequence
+	p.Config.Req.Feeder.Sharing = FeederShared
+	p.Config.compileTemplates(&p.Config.Req)
+	p.Config.Exec.DurationSeconds = 10
+	p.Race(context.Background())
+
+	if p.ReqStats.ReqAtmpts != 4 || atomic.LoadInt64(&reqs) != 4 {
+		t.Errorf("should send each row once, got %v attempts %v requests", p.ReqStats.ReqAtmpts, reqs)
+	}
+	if p.Time.Stop.Sub(p.Time.Start).Seconds() >= 10 {
+		t.Error("exhausted feeder should end the test early")
+	}
+}
+
+func TestRaceWithUnevenPartitionedFeeder(t *testing.T) {
+	var reqs int64
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//keep the slot with two rows busy while the others run dry.
+		if r.URL.Query().Get("user") == "alice" {
+			time.Sleep(time.Second)
+		}
+		if r.URL.Query().Get("user") != "" {
+			atomic.AddInt64(&reqs, 1)
+		}
+	}))
+	defer svr.Close()
+
+	cfg := loadConfigFromFile("./examples/config_feeder.yml")
+	cfg.Req.Url = svr.URL + "/?user={{.user}}&term={{.term}}"
+	cfg.Req.Feeder.Mode = FeederSequence
+	cfg.Exec.DurationSeconds = 10
+	cfg.Exec.RampSeconds = 1
+	cfg.Exec.Concurrency = 3
+	p, e := NewP0dFromConfig(*cfg, "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	p.Race(context.Background())
+
+	//four rows over three slots, the first slot has two of them.
+	if p.ReqStats.ReqAtmpts != 4 || atomic.LoadInt64(&reqs) != 4 {
+		t.Errorf("should send each row once, got %v attempts %v requests", p.ReqStats.ReqAtmpts, reqs)
+	}
+	if p.Time.Stop.Sub(p.Time.Start).Seconds() >= 10 {
+		t.Error("feeder exhausted on every slot should end the test early")
+	}
+}
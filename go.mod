@@ -15,6 +15,7 @@ require (
 	github.com/simonmittag/procspy v0.0.8
 	github.com/spenczar/tdigest v2.1.0+incompatible
 	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2
+	golang.org/x/term v0.27.0
 )
 
 require (
@@ -149,7 +150,6 @@ require (
 	golang.org/x/mod v0.4.2 // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 	golang.org/x/sys v0.28.0 // indirect
-	golang.org/x/term v0.27.0 // indirect
 	golang.org/x/text v0.3.6 // indirect
 	golang.org/x/tools v0.1.5 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
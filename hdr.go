@@ -0,0 +1,307 @@
+package p0d
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const hdrEncodingCookie = 0x1c849303 | 0x10
+const hdrCompressedEncodingCookie = 0x1c849304 | 0x10
+
+type HdrHistogram struct {
+	lowest                      int64
+	highest                     int64
+	sigFigs                     int
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	subBucketCount              int64
+	subBucketHalfCount          int64
+	subBucketMask               int64
+	leadingZeroCountBase        int
+	counts                      []int64
+	total                       int64
+	min                         int64
+	max                         int64
+}
+
+func NewHdrHistogram(lowest int64, highest int64, sigFigs int) *HdrHistogram {
+	h := &HdrHistogram{lowest: lowest, highest: highest, sigFigs: sigFigs}
+	largestSingleUnit := 2 * int64(math.Pow10(sigFigs))
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	if subBucketCountMagnitude < 1 {
+		subBucketCountMagnitude = 1
+	}
+	h.subBucketHalfCountMagnitude = subBucketCountMagnitude - 1
+	h.unitMagnitude = uint(math.Floor(math.Log2(float64(lowest))))
+	h.subBucketCount = 1 << (h.subBucketHalfCountMagnitude + 1)
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = (h.subBucketCount - 1) << h.unitMagnitude
+	h.leadingZeroCountBase = 64 - int(h.unitMagnitude) - int(h.subBucketHalfCountMagnitude) - 1
+
+	//enough buckets, each twice the range of the one before, to cover the highest value.
+	smallestUntrackable := h.subBucketCount << h.unitMagnitude
+	buckets := 1
+	for smallestUntrackable <= highest {
+		if smallestUntrackable > math.MaxInt64/2 {
+			buckets++
+			break
+		}
+		smallestUntrackable <<= 1
+		buckets++
+	}
+	h.counts = make([]int64, (buckets+1)*int(h.subBucketHalfCount))
+	h.reset()
+	return h
+}
+
+func (h *HdrHistogram) reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.total = 0
+	h.min = math.MaxInt64
+	h.max = 0
+}
+
+func (h *HdrHistogram) bucketIndex(v int64) int {
+	return h.leadingZeroCountBase - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+}
+
+func (h *HdrHistogram) countsIndex(v int64) int {
+	bi := h.bucketIndex(v)
+	sbi := v >> (uint(bi) + h.unitMagnitude)
+	return (bi+1)<<h.subBucketHalfCountMagnitude + int(sbi-h.subBucketHalfCount)
+}
+
+func (h *HdrHistogram) valueFromIndex(i int) int64 {
+	bi := (i >> h.subBucketHalfCountMagnitude) - 1
+	sbi := int64(i)&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bi < 0 {
+		sbi -= h.subBucketHalfCount
+		bi = 0
+	}
+	return sbi << (uint(bi) + h.unitMagnitude)
+}
+
+func (h *HdrHistogram) highestEquivalent(v int64) int64 {
+	shift := uint(h.bucketIndex(v)) + h.unitMagnitude
+	return (v>>shift)<<shift + (int64(1) << shift) - 1
+}
+
+func (h *HdrHistogram) Record(v int64) {
+	h.RecordN(v, 1)
+}
+
+func (h *HdrHistogram) RecordN(v int64, n int64) {
+	//values outside the range still count, at the edges
+	if v < 0 {
+		v = 0
+	}
+	if v > h.highest {
+		v = h.highest
+	}
+	h.counts[h.countsIndex(v)] += n
+	h.total += n
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+func (h *HdrHistogram) TotalCount() int64 {
+	return h.total
+}
+
+func (h *HdrHistogram) Min() int64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.min
+}
+
+func (h *HdrHistogram) Max() int64 {
+	if h.total == 0 {
+		return 0
+	}
+	//like HdrHistogram, report the highest value that's equivalent to the max we saw
+	return h.highestEquivalent(h.max)
+}
+
+func (h *HdrHistogram) ValueAtQuantile(q float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	q = math.Min(math.Max(q, 0), 1)
+	at := int64(math.Ceil(q * float64(h.total)))
+	if at < 1 {
+		at = 1
+	}
+	sum := int64(0)
+	for i, c := range h.counts {
+		sum += c
+		if sum >= at {
+			return h.highestEquivalent(h.valueFromIndex(i))
+		}
+	}
+	return h.Max()
+}
+
+func (h *HdrHistogram) Merge(o *HdrHistogram) {
+	//same layout adds up index by index, otherwise each count goes in by value.
+	same := h.lowest == o.lowest && h.highest == o.highest && h.sigFigs == o.sigFigs
+	for i, c := range o.counts {
+		if c == 0 {
+			continue
+		}
+		if same {
+			h.counts[i] += c
+			h.total += c
+		} else {
+			h.RecordN(o.valueFromIndex(i), c)
+		}
+	}
+	if o.total > 0 {
+		h.min = min(h.min, o.min)
+		h.max = max(h.max, o.max)
+	}
+}
+
+func putZigZag(b *bytes.Buffer, v int64) {
+	u := uint64((v << 1) ^ (v >> 63))
+	for i := 0; i < 8; i++ {
+		if u>>(7*(uint(i)+1)) == 0 {
+			b.WriteByte(byte(u >> (7 * uint(i))))
+			return
+		}
+		b.WriteByte(byte(u>>(7*uint(i)))&0x7f | 0x80)
+	}
+	b.WriteByte(byte(u >> 56))
+}
+
+func (h *HdrHistogram) encode() []byte {
+	//counts up to the max only, with runs of zeros as a single negative count.
+	payload := bytes.Buffer{}
+	if h.total > 0 {
+		limit := h.countsIndex(h.max) + 1
+		for i := 0; i < limit; {
+			c := h.counts[i]
+			i++
+			if c == 0 {
+				zeros := int64(1)
+				for i < limit && h.counts[i] == 0 {
+					zeros++
+					i++
+				}
+				if zeros > 1 {
+					putZigZag(&payload, -zeros)
+					continue
+				}
+			}
+			putZigZag(&payload, c)
+		}
+	}
+
+	//V2 header as in HdrHistogram's AbstractHistogram.encodeIntoByteBuffer, so its tools can read us.
+	b := bytes.Buffer{}
+	binary.Write(&b, binary.BigEndian, int32(hdrEncodingCookie))
+	binary.Write(&b, binary.BigEndian, int32(payload.Len()))
+	binary.Write(&b, binary.BigEndian, int32(0))
+	binary.Write(&b, binary.BigEndian, int32(h.sigFigs))
+	binary.Write(&b, binary.BigEndian, h.lowest)
+	binary.Write(&b, binary.BigEndian, h.highest)
+	binary.Write(&b, binary.BigEndian, float64(1))
+	b.Write(payload.Bytes())
+	return b.Bytes()
+}
+
+func (h *HdrHistogram) EncodeCompressed() string {
+	z := bytes.Buffer{}
+	zw := zlib.NewWriter(&z)
+	zw.Write(h.encode())
+	zw.Close()
+
+	b := bytes.Buffer{}
+	binary.Write(&b, binary.BigEndian, int32(hdrCompressedEncodingCookie))
+	binary.Write(&b, binary.BigEndian, int32(z.Len()))
+	b.Write(z.Bytes())
+	return base64.StdEncoding.EncodeToString(b.Bytes())
+}
+
+func getZigZag(r *bytes.Reader) (int64, error) {
+	var u uint64
+	for s := uint(0); s < 64; s += 7 {
+		c, e := r.ReadByte()
+		if e != nil {
+			return 0, e
+		}
+		//the ninth byte carries all 8 bits
+		if s == 56 {
+			u |= uint64(c) << s
+			break
+		}
+		u |= uint64(c&0x7f) << s
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+func DecodeHdrHistogram(s string) (*HdrHistogram, error) {
+	b, e := base64.StdEncoding.DecodeString(s)
+	if e != nil {
+		return nil, e
+	}
+	if len(b) < 8 || binary.BigEndian.Uint32(b)&^0xf0 != hdrCompressedEncodingCookie&^0xf0 {
+		return nil, fmt.Errorf("not a compressed hdr histogram")
+	}
+	zr, e := zlib.NewReader(bytes.NewReader(b[8:]))
+	if e != nil {
+		return nil, e
+	}
+	raw, e := io.ReadAll(zr)
+	if e != nil {
+		return nil, e
+	}
+	if len(raw) < 40 || binary.BigEndian.Uint32(raw)&^0xf0 != hdrEncodingCookie&^0xf0 {
+		return nil, fmt.Errorf("not a V2 hdr histogram")
+	}
+	sigFigs := int(binary.BigEndian.Uint32(raw[12:]))
+	lowest := int64(binary.BigEndian.Uint64(raw[16:]))
+	highest := int64(binary.BigEndian.Uint64(raw[24:]))
+	if sigFigs < 1 || sigFigs > 5 || lowest < 1 || highest < 2*lowest {
+		return nil, fmt.Errorf("hdr histogram header out of range")
+	}
+	h := NewHdrHistogram(lowest, highest, sigFigs)
+
+	r := bytes.NewReader(raw[40:])
+	for i := 0; r.Len() > 0; {
+		v, e := getZigZag(r)
+		if e != nil {
+			return nil, e
+		}
+		if v < 0 {
+			i += int(-v)
+			continue
+		}
+		if i >= len(h.counts) {
+			return nil, fmt.Errorf("hdr histogram has more counts than fit its range")
+		}
+		if v > 0 {
+			h.counts[i] = v
+			h.total += v
+			h.min = min(h.min, h.valueFromIndex(i))
+			h.max = max(h.max, h.valueFromIndex(i))
+		}
+		i++
+	}
+	return h, nil
+}
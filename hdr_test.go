@@ -0,0 +1,139 @@
+package p0d
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+type hdrIndexTest struct {
+	v     int64
+	index int
+}
+
+func TestHdrCountsIndex(t *testing.T) {
+	h := NewHdrHistogram(1, 3600*1000*1000, 3)
+	tests := []hdrIndexTest{
+		{0, 0},
+		{1, 1},
+		{2047, 2047},
+		//the second bucket has half the sub buckets at twice the width
+		{2048, 2048},
+		{2049, 2048},
+		{4095, 3071},
+		{4096, 3072},
+	}
+	for _, tt := range tests {
+		if i := h.countsIndex(tt.v); i != tt.index {
+			t.Errorf("counts index for %d should be %d, got %d", tt.v, tt.index, i)
+		}
+	}
+}
+
+func TestHdrMax(t *testing.T) {
+	h := NewHdrHistogram(1, 3600*1000*1000, 3)
+	if h.Max() != 0 {
+		t.Error("empty histogram should have no max")
+	}
+	h.Record(1000)
+	h.Record(4096)
+	if h.Max() != 4099 || h.TotalCount() != 2 {
+		t.Errorf("max should be highest equivalent value, got %d", h.Max())
+	}
+}
+
+func TestHdrEncodeCompressed(t *testing.T) {
+	h := NewHdrHistogram(hdrLowestNs, hdrHighestNs, hdrSigFigs)
+	h.Record(int64(time.Millisecond))
+	h.Record(int64(time.Millisecond))
+	h.Record(int64(time.Second))
+
+	b, e := base64.StdEncoding.DecodeString(h.EncodeCompressed())
+	if e != nil {
+		t.Fatalf("should be base64, got %v", e)
+	}
+	if c := binary.BigEndian.Uint32(b); c&^0xf0 != 0x1c849304 {
+		t.Errorf("compressed cookie incorrect, got %x", c)
+	}
+	if l := binary.BigEndian.Uint32(b[4:]); int(l) != len(b)-8 {
+		t.Errorf("compressed length incorrect, got %d", l)
+	}
+	zr, e := zlib.NewReader(bytes.NewReader(b[8:]))
+	if e != nil {
+		t.Fatalf("should be zlib, got %v", e)
+	}
+	raw, _ := io.ReadAll(zr)
+
+	if c := binary.BigEndian.Uint32(raw); c&^0xf0 != 0x1c849303 {
+		t.Errorf("cookie incorrect, got %x", c)
+	}
+	payload := int(binary.BigEndian.Uint32(raw[4:]))
+	if payload != len(raw)-40 || binary.BigEndian.Uint32(raw[12:]) != hdrSigFigs ||
+		int64(binary.BigEndian.Uint64(raw[16:])) != hdrLowestNs || int64(binary.BigEndian.Uint64(raw[24:])) != hdrHighestNs {
+		t.Error("header incorrect")
+	}
+
+	//zigzag LEB128, negative values are runs of zeros
+	var total, slots int64
+	r := bytes.NewReader(raw[40:])
+	for r.Len() > 0 {
+		var u uint64
+		for s := uint(0); ; s += 7 {
+			c, _ := r.ReadByte()
+			if s == 56 {
+				u |= uint64(c) << s
+				break
+			}
+			u |= uint64(c&0x7f) << s
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		v := int64(u>>1) ^ -int64(u&1)
+		if v < 0 {
+			slots += -v
+		} else {
+			total += v
+			slots++
+		}
+	}
+	if total != 3 || slots != int64(h.countsIndex(int64(time.Second))+1) {
+		t.Errorf("payload should decode to all counts, got %d counts in %d slots", total, slots)
+	}
+}
+
+func TestPutZigZag(t *testing.T) {
+	b := bytes.Buffer{}
+	putZigZag(&b, 1)
+	putZigZag(&b, -1)
+	putZigZag(&b, 64)
+	if !bytes.Equal(b.Bytes(), []byte{2, 1, 0x80, 1}) {
+		t.Errorf("zigzag incorrect, got %v", b.Bytes())
+	}
+	b.Reset()
+	putZigZag(&b, -1<<62)
+	if b.Len() != 9 {
+		t.Errorf("large values should take 9 bytes, got %d", b.Len())
+	}
+}
+
+func TestDecodeHdrHistogram(t *testing.T) {
+	h := NewHdrHistogram(hdrLowestNs, hdrHighestNs, 2)
+	for _, v := range []int64{1000, 5000, 5000, int64(time.Second)} {
+		h.Record(v)
+	}
+	d, e := DecodeHdrHistogram(h.EncodeCompressed())
+	if e != nil {
+		t.Fatalf("should decode, got %v", e)
+	}
+	if d.TotalCount() != 4 || d.sigFigs != 2 || d.Max() != h.Max() || d.ValueAtQuantile(0.5) != h.ValueAtQuantile(0.5) {
+		t.Errorf("decoded histogram incorrect, got %d %d", d.TotalCount(), d.Max())
+	}
+	if _, e := DecodeHdrHistogram("bm90IGhkcg=="); e == nil {
+		t.Error("should not decode garbage")
+	}
+}
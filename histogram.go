@@ -0,0 +1,40 @@
+package p0d
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	HistogramTDigest = "tdigest"
+	HistogramHdr     = "hdr"
+)
+
+type Histogram struct {
+	Type      string
+	Precision int
+}
+
+func (cfg *Config) validateHistogram() {
+	hs := &cfg.Exec.Histogram
+	hs.Type = strings.ToLower(hs.Type)
+	if hs.Type == N {
+		hs.Type = HistogramTDigest
+	}
+	if hs.Type != HistogramTDigest && hs.Type != HistogramHdr {
+		cfg.panic(fmt.Sprintf("histogram type must be one of %s, %s, yours: %s", HistogramTDigest, HistogramHdr, hs.Type))
+	}
+	if hs.Precision == 0 {
+		hs.Precision = 3
+	}
+	if hs.Precision < 1 || hs.Precision > 4 {
+		cfg.panic(fmt.Sprintf("histogram precision must be between 1 and 4 significant digits, yours: %d", hs.Precision))
+	}
+}
+
+func (cfg Config) newQuantile() *Quantile {
+	if cfg.Exec.Histogram.Type == HistogramHdr {
+		return NewHdrQuantile(cfg.Exec.Histogram.Precision)
+	}
+	return NewQuantileWithCompression(500)
+}
@@ -0,0 +1,101 @@
+package p0d
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValidateHistogram(t *testing.T) {
+	cfg := Config{}
+	cfg.validateHistogram()
+	if cfg.Exec.Histogram.Type != HistogramTDigest || cfg.Exec.Histogram.Precision != 3 {
+		t.Error("histogram defaults incorrect")
+	}
+	cfg = Config{Exec: Exec{Histogram: Histogram{Type: "HDR", Precision: 2}}}
+	cfg.validateHistogram()
+	if cfg.newQuantile().h == nil || cfg.newQuantile().h.sigFigs != 2 {
+		t.Error("hdr histogram should be configurable")
+	}
+}
+
+func TestHdrQuantile(t *testing.T) {
+	q := NewHdrQuantile(3)
+	if !math.IsNaN(q.Quantile(0.5)) {
+		t.Error("empty quantile should be NaN")
+	}
+	for i := 1; i <= 10000; i++ {
+		q.Add(float64(time.Duration(i)*time.Microsecond), 1)
+	}
+	q.Add(float64(time.Second+7), 1)
+
+	if q.Quantile(0) != float64(time.Microsecond) || q.Quantile(1) != float64(time.Second+7) {
+		t.Errorf("min and max should be exact, got %v %v", q.Quantile(0), q.Quantile(1))
+	}
+	within := func(got float64, want time.Duration) bool {
+		return math.Abs(got-float64(want)) <= float64(want)/1000
+	}
+	if !within(q.Quantile(0.5), 5*time.Millisecond) || !within(q.Quantile(0.999), 9990*time.Microsecond) {
+		t.Errorf("quantiles should be within precision, got %v %v", q.Quantile(0.5), q.Quantile(0.999))
+	}
+	if !within(q.Quantile(0.9999), 10*time.Millisecond) {
+		t.Errorf("p99.99 should be within precision, got %v", q.Quantile(0.9999))
+	}
+}
+
+func TestHdrQuantileMerge(t *testing.T) {
+	a := NewHdrQuantile(3)
+	b := NewHdrQuantile(3)
+	all := NewHdrQuantile(3)
+	for i := 1; i <= 1000; i++ {
+		v := float64(time.Duration(i) * time.Millisecond)
+		if i%2 == 0 {
+			a.Add(v, 1)
+		} else {
+			b.Add(v, 1)
+		}
+		all.Add(v, 1)
+	}
+	if e := a.Merge(b); e != nil {
+		t.Fatalf("should merge, got %v", e)
+	}
+	for _, v := range []float64{0, 0.5, 0.9, 0.99, 0.999, 1} {
+		if a.Quantile(v) != all.Quantile(v) {
+			t.Errorf("merged quantile %v should be exact, got %v want %v", v, a.Quantile(v), all.Quantile(v))
+		}
+	}
+	if NewQuantile().Merge(b) == nil {
+		t.Error("tdigest should not merge")
+	}
+}
+
+func TestHdrQuantileJson(t *testing.T) {
+	q := NewHdrQuantile(3)
+	for i := 1; i <= 100; i++ {
+		q.Add(float64(time.Duration(i)*time.Millisecond+3), 1)
+	}
+	j, _ := json.Marshal(q)
+	var m map[string]any
+	json.Unmarshal(j, &m)
+	for _, k := range quantileKeys {
+		if _, ok := m[k]; !ok {
+			t.Errorf("json should have %s", k)
+		}
+	}
+
+	r := &Quantile{}
+	if e := json.Unmarshal(j, r); e != nil {
+		t.Fatalf("should restore hdr quantile, got %v", e)
+	}
+	for _, v := range []float64{0, 0.5, 0.99, 1} {
+		if math.Ceil(r.Quantile(v)) != math.Ceil(q.Quantile(v)) {
+			t.Errorf("restored quantile %v incorrect, got %v want %v", v, r.Quantile(v), q.Quantile(v))
+		}
+	}
+
+	j, _ = json.Marshal(NewQuantile())
+	if json.Unmarshal(j, &Quantile{}) == nil {
+		t.Error("tdigest should not restore")
+	}
+}
@@ -0,0 +1,255 @@
+package p0d
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Scenario struct {
+	Steps []Step
+}
+
+type Step struct {
+	Name    string
+	Req     Req
+	Extract []Extract
+}
+
+type Extract struct {
+	Var      string
+	Header   string
+	JsonPath string
+	Regex    string
+
+	regex *regexp.Regexp
+}
+
+type JourneyAtmpt struct {
+	Start   time.Time
+	Stop    time.Time
+	ElpsdNs time.Duration
+	Steps   int
+	Success bool
+	Err     string
+}
+
+func (cfg *Config) isScenario() bool {
+	return len(cfg.Scenario.Steps) > 0
+}
+
+func (cfg *Config) validateScenario() {
+	for i := range cfg.Scenario.Steps {
+		s := &cfg.Scenario.Steps[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("step %d", i+1)
+		}
+		//step stats are kept under the step name, same as request templates.
+		s.Req.Name = s.Name
+		cfg.validateReq(&s.Req)
+		for j := range s.Extract {
+			cfg.validateExtract(s.Name, &s.Extract[j])
+		}
+	}
+	cfg.validateReqNames()
+	cfg.Req = cfg.Scenario.Steps[0].Req
+}
+
+func (cfg *Config) validateExtract(step string, e *Extract) {
+	if e.Var == "" {
+		cfg.panic(fmt.Sprintf("%s extract needs a var name", step))
+	}
+	if e.Header == "" && e.JsonPath == "" && e.Regex == "" {
+		cfg.panic(fmt.Sprintf("%s extract '%s' needs one of header, jsonPath or regex", step, e.Var))
+	}
+	if e.Header != "" && e.JsonPath != "" {
+		cfg.panic(fmt.Sprintf("%s extract '%s' cannot use header and jsonPath", step, e.Var))
+	}
+	if e.JsonPath != "" && !strings.HasPrefix(e.JsonPath, "$") {
+		cfg.panic(fmt.Sprintf("%s extract '%s' jsonPath must start with $", step, e.Var))
+	}
+	if e.Regex != "" {
+		r, err := regexp.Compile(e.Regex)
+		if err != nil {
+			cfg.panic(fmt.Sprintf("%s extract '%s' has bad regex: %s", step, e.Var, err.Error()))
+		}
+		e.regex = r
+	}
+}
+
+func (p *P0d) doJourneyAtmpt(i int, intended time.Time, ras chan<- ReqAtmpt) bool {
+	j := JourneyAtmpt{
+		Start: time.Now(),
+	}
+	//every virtual user starts each journey with a clean set of variables.
+	vars := make(map[string]string)
+
+	var last ReqAtmpt
+	for k := range p.Config.Scenario.Steps {
+		s := &p.Config.Scenario.Steps[k]
+		var extractErr string
+		onRes := func(res *http.Response) {
+			if len(s.Extract) > 0 {
+				extractErr = s.extractVars(res, vars)
+			}
+		}
+
+		//a journey can't continue without data for its step.
+		if _, ok := s.Req.feed(i, vars); !ok {
+			p.feederExhausted(s.Req.Feeder, i)
+			return false
+		}
+
+		//only the first step has an intended start, the rest follow on.
+		ra := p.doReqAtmptFor(i, intended, &s.Req, vars, onRes)
+		intended = time.Time{}
+		j.Steps++
+
+		if ra.ResErr != N {
+			j.Err = fmt.Sprintf("%s: %s", s.Name, ra.ResErr)
+		} else if !p.Config.Res.matchesCode(ra.ResCode) {
+			j.Err = fmt.Sprintf("%s: HTTP %d", s.Name, ra.ResCode)
+		} else if len(ra.AssertFails) > 0 {
+			j.Err = fmt.Sprintf("%s: failed %s", s.Name, ra.AssertFails[0])
+		} else if extractErr != N {
+			j.Err = fmt.Sprintf("%s: %s", s.Name, extractErr)
+		}
+
+		if j.Err != N || k == len(p.Config.Scenario.Steps)-1 {
+			last = ra
+			break
+		}
+		ras <- ra
+	}
+
+	j.Success = j.Err == N
+	j.Stop = time.Now()
+	j.ElpsdNs = j.Stop.Sub(j.Start)
+
+	//the journey reports back with its last step.
+	last.Journey = &j
+	ras <- last
+	return true
+}
+
+func (s *Step) extractVars(res *http.Response, vars map[string]string) string {
+	var body []byte
+	for _, e := range s.Extract {
+		if e.Header == "" && body == nil {
+			body, _ = io.ReadAll(res.Body)
+		}
+		v, ok := e.extract(res, body)
+		if !ok {
+			return fmt.Sprintf("unable to extract %s", e.Var)
+		}
+		vars[e.Var] = v
+	}
+	return N
+}
+
+func (e *Extract) extract(res *http.Response, body []byte) (string, bool) {
+	var src string
+	if e.Header != "" {
+		src = res.Header.Get(e.Header)
+		if src == "" {
+			return N, false
+		}
+	} else if e.JsonPath != "" {
+		v, ok := jsonPath(body, e.JsonPath)
+		if !ok {
+			return N, false
+		}
+		src = v
+	} else {
+		src = string(body)
+	}
+
+	if e.regex != nil {
+		m := e.regex.FindStringSubmatch(src)
+		if m == nil {
+			return N, false
+		}
+		//use the first capture group if there is one.
+		if len(m) > 1 {
+			return m[1], true
+		}
+		return m[0], true
+	}
+	return src, true
+}
+
+func jsonPath(body []byte, path string) (string, bool) {
+	var doc any
+	if json.Unmarshal(body, &doc) != nil {
+		return N, false
+	}
+
+	v, ok := jsonPathValue(doc, path)
+	if !ok {
+		return N, false
+	}
+	if str, isStr := v.(string); isStr {
+		return str, true
+	}
+	j, _ := json.Marshal(v)
+	return string(j), true
+}
+
+func jsonPathValue(doc any, path string) (any, bool) {
+	//supports the subset we need for extraction: $.key.other[0]['quoted key']
+	p := strings.TrimPrefix(path, "$")
+	cur := doc
+	for len(p) > 0 {
+		switch {
+		case strings.HasPrefix(p, "['"):
+			end := strings.Index(p, "']")
+			if end < 0 {
+				return nil, false
+			}
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			cur, ok = m[p[2:end]]
+			if !ok {
+				return nil, false
+			}
+			p = p[end+2:]
+		case strings.HasPrefix(p, "["):
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, false
+			}
+			idx, err := strconv.Atoi(p[1:end])
+			a, ok := cur.([]any)
+			if err != nil || !ok || idx < 0 || idx >= len(a) {
+				return nil, false
+			}
+			cur = a[idx]
+			p = p[end+1:]
+		case strings.HasPrefix(p, "."):
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			cur, ok = m[p[:end]]
+			if !ok {
+				return nil, false
+			}
+			p = p[end:]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
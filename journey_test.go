@@ -0,0 +1,172 @@
+package p0d
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func journeyTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprintf(w, `{"token": "abc", "user": {"roles": ["admin"]}}`)
+		case "/items/1":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.Method == "GET" {
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprintf(w, `{"id": 1}`)
+				return
+			}
+			if r.Header.Get("If-Match") != `"v1"` {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			fmt.Fprintf(w, `{"id": 1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func scenarioP0d(url string) *P0d {
+	cfg := loadConfigFromFile("./examples/config_scenario.yml")
+	for i := range cfg.Scenario.Steps {
+		//point the steps at our mock server so we can execute the test
+		s := &cfg.Scenario.Steps[i]
+		s.Req.Url = url + s.Req.Url[len("http://localhost:60083"):]
+	}
+	cfg.validate()
+	return NewP0d(*cfg, 1024, "", cfg.Exec.DurationSeconds, interruptChannel())
+}
+
+func TestScenarioConfigValidate(t *testing.T) {
+	cfg := loadConfigFromFile("./examples/config_scenario.yml")
+	cfg.validate()
+
+	if !cfg.isScenario() || len(cfg.Scenario.Steps) != 3 {
+		t.Error("scenario not parsed")
+	}
+	if cfg.Req.Name != "login" {
+		t.Error("first step should stand in for req")
+	}
+	if cfg.Scenario.Steps[1].Req.Name != "get item" {
+		t.Error("step name should name its request")
+	}
+	if len(cfg.reqs()) != 3 || !cfg.hasReqTemplates() {
+		t.Error("steps should be request templates")
+	}
+	if cfg.Scenario.Steps[0].Extract[0].JsonPath != "$.token" {
+		t.Error("extract not parsed")
+	}
+}
+
+func TestDoJourneyAtmpt(t *testing.T) {
+	svr := journeyTestServer()
+	defer svr.Close()
+
+	p := scenarioP0d(svr.URL)
+	p.ReqStats.setStart(p.Time.Start)
+	ras := make(chan ReqAtmpt, 16)
+	p.doJourneyAtmpt(0, p.Time.Start, ras)
+
+	if len(ras) != 3 {
+		t.Fatalf("should have reported 3 steps, got %v", len(ras))
+	}
+	for i := 0; i < 3; i++ {
+		ra := <-ras
+		if ra.ResCode != 200 {
+			t.Errorf("step %s should have returned 200, got %v", ra.ReqName, ra.ResCode)
+		}
+		if i < 2 && ra.Journey != nil {
+			t.Error("only last step should report the journey")
+		}
+		if i == 2 {
+			if ra.Journey == nil || !ra.Journey.Success || ra.Journey.Steps != 3 {
+				t.Errorf("journey should have succeeded, got %+v", ra.Journey)
+			}
+		}
+		p.ReqStats.update(ra, ra.Stop, p.Config)
+	}
+
+	if p.ReqStats.JourneyAtmpts != 1 || p.ReqStats.PctJourneySuccess != 100 {
+		t.Error("journey stats incorrect")
+	}
+	if p.ReqStats.Reqs["get item"].ReqAtmpts != 1 || p.ReqStats.Reqs["get item"].JourneyAtmpts != 0 {
+		t.Error("step stats incorrect")
+	}
+}
+
+func TestDoJourneyAtmptFailedExtract(t *testing.T) {
+	svr := journeyTestServer()
+	defer svr.Close()
+
+	p := scenarioP0d(svr.URL)
+	p.Config.Scenario.Steps[0].Extract[0].JsonPath = "$.missing"
+
+	ras := make(chan ReqAtmpt, 16)
+	p.doJourneyAtmpt(0, p.Time.Start, ras)
+
+	if len(ras) != 1 {
+		t.Fatalf("journey should stop after failed step, got %v steps", len(ras))
+	}
+	ra := <-ras
+	if ra.Journey == nil || ra.Journey.Success {
+		t.Error("journey should have failed")
+	}
+	if ra.Journey.Err != "login: unable to extract token" {
+		t.Errorf("journey error incorrect, got %v", ra.Journey.Err)
+	}
+}
+
+func TestJsonPath(t *testing.T) {
+	body := []byte(`{"token": "abc", "n": 42, "user": {"roles": ["admin", "ops"], "first name": "p0d"}}`)
+
+	type jsonPathTest struct {
+		path   string
+		want   string
+		wantOk bool
+	}
+
+	tests := []jsonPathTest{
+		{path: "$.token", want: "abc", wantOk: true},
+		{path: "$.n", want: "42", wantOk: true},
+		{path: "$.user.roles[1]", want: "ops", wantOk: true},
+		{path: "$.user['first name']", want: "p0d", wantOk: true},
+		{path: "$.user.roles", want: `["admin","ops"]`, wantOk: true},
+		{path: "$.user.roles[2]", want: "", wantOk: false},
+		{path: "$.missing", want: "", wantOk: false},
+		{path: "$.token.nested", want: "", wantOk: false},
+	}
+
+	for _, tc := range tests {
+		got, ok := jsonPath(body, tc.path)
+		if got != tc.want || ok != tc.wantOk {
+			t.Errorf("bad json path %v, want %v/%v got %v/%v", tc.path, tc.want, tc.wantOk, got, ok)
+		}
+	}
+
+	if _, ok := jsonPath([]byte("not json"), "$.token"); ok {
+		t.Error("should not extract from invalid json")
+	}
+}
+
+func TestRaceWithScenario(t *testing.T) {
+	svr := journeyTestServer()
+	defer svr.Close()
+
+	p := scenarioP0d(svr.URL)
+	p.Config.Exec.Concurrency = 2
+	p.Config.Exec.DurationSeconds = 1
+	p.Race(context.Background())
+
+	if p.ReqStats.JourneyAtmpts == 0 || p.ReqStats.SumJourneySuccess != int(p.ReqStats.JourneyAtmpts) {
+		t.Errorf("all journeys should have succeeded, got %v/%v",
+			p.ReqStats.SumJourneySuccess, p.ReqStats.JourneyAtmpts)
+	}
+}
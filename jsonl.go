@@ -0,0 +1,58 @@
+package p0d
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	RecordAttempt  = "attempt"
+	RecordSnapshot = "snapshot"
+	RecordSummary  = "summary"
+)
+
+type Record struct {
+	Type string
+	Time time.Time
+	Data any
+}
+
+func (p *P0d) isJsonl() bool {
+	ext := strings.ToLower(filepath.Ext(p.Output))
+	return ext == ".jsonl" || ext == ".ndjson"
+}
+
+func isJsonl(j []byte) bool {
+	//a run saved as plain json has no record type
+	var r struct {
+		Type string
+	}
+	d := json.NewDecoder(bytes.NewReader(j))
+	return len(j) > 0 && j[0] == '{' && d.Decode(&r) == nil && r.Type != N
+}
+
+func parseJsonl(j []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(j))
+	var summary json.RawMessage
+	for d.More() {
+		var r struct {
+			Type string
+			Data json.RawMessage
+		}
+		if e := d.Decode(&r); e != nil {
+			//a crashed run can leave a partial last line, what we have so far is still good.
+			break
+		}
+		if r.Type == RecordSummary {
+			summary = r.Data
+		}
+	}
+	if summary == nil {
+		return nil, fmt.Errorf("no %s record found, the run may not have finished", RecordSummary)
+	}
+	return summary, nil
+}
@@ -0,0 +1,82 @@
+package p0d
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestIsJsonl(t *testing.T) {
+	p := P0d{Output: "run.JSONL"}
+	if !p.isJsonl() {
+		t.Error(".jsonl output should write json lines")
+	}
+	p.Output = "run.json"
+	if p.isJsonl() {
+		t.Error(".json output should write a json array")
+	}
+	if !isJsonl([]byte(`{"Type":"attempt","Data":{}}`)) || isJsonl([]byte(`{"ID":"abc"}`)) || isJsonl([]byte(`[]`)) {
+		t.Error("json lines detection incorrect")
+	}
+}
+
+func TestParseJsonl(t *testing.T) {
+	j := []byte(`{"Type":"attempt","Data":{"ResCode":200}}
+{"Type":"snapshot","Data":{"ReqAtmpts":1}}
+{"Type":"summary","Data":{"ID":"abc","ReqStats":{"ReqAtmpts":1}}}
+`)
+	r, e := parseReport(j)
+	if e != nil || r.ID != "abc" || r.ReqStats.ReqAtmpts != 1 {
+		t.Errorf("should parse summary record, got %v %v", r, e)
+	}
+
+	//crashed mid write, there is no summary and the last line is broken
+	_, e = parseReport([]byte(`{"Type":"attempt","Data":{"ResCode":200}}
+{"Type":"snapsh`))
+	if e == nil {
+		t.Error("should fail without summary record")
+	}
+}
+
+func TestRaceWithJsonlOutput(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get.yml", "testoutput.jsonl")
+	defer os.Remove(p.Output)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p.Config.Req.Url = svr.URL
+	p.Config.Exec.DurationSeconds = 1
+	p.Race(context.Background())
+
+	f, _ := os.Open(p.Output)
+	defer f.Close()
+	types := make(map[string]int)
+	last := N
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1<<20), 1<<24)
+	for sc.Scan() {
+		var r struct {
+			Type string
+			Data json.RawMessage
+		}
+		if e := json.Unmarshal(sc.Bytes(), &r); e != nil {
+			t.Fatalf("each line should be a json record, got %v", e)
+		}
+		types[r.Type]++
+		last = r.Type
+	}
+	if types[RecordAttempt] == 0 || types[RecordSnapshot] == 0 || types[RecordSummary] != 1 || last != RecordSummary {
+		t.Errorf("should write attempts, snapshots and one summary last, got %v", types)
+	}
+	if _, e := loadRun(p.Output); e != nil {
+		t.Errorf("json lines output should load for reports, got %v", e)
+	}
+}
@@ -0,0 +1,199 @@
+package p0d
+
+import (
+	"bufio"
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const metricsPath = "/metrics"
+
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var timerPhases = []TimerPhase{Bootstrap, RampUp, Main, RampDown, Draining, Drained, Done}
+
+var timerPhaseNames = map[TimerPhase]string{
+	Bootstrap: "bootstrap",
+	RampUp:    "rampup",
+	Main:      "main",
+	RampDown:  "rampdown",
+	Draining:  "draining",
+	Drained:   "drained",
+	Done:      "done",
+}
+
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBuckets))}
+}
+
+func (h *histogram) observe(secs float64) {
+	for i, b := range latencyBuckets {
+		if secs <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+func (h *histogram) write(w *bufio.Writer, name string) {
+	for i, b := range latencyBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(b, 'f', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'f', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+type Metrics struct {
+	lock         sync.Mutex
+	codes        map[int]int64
+	errors       map[string]int64
+	latency      *histogram
+	corrected    *histogram
+	bytesRead    int64
+	bytesWritten int64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		codes:     make(map[int]int64),
+		errors:    make(map[string]int64),
+		latency:   newHistogram(),
+		corrected: newHistogram(),
+	}
+}
+
+func (m *Metrics) observe(ra ReqAtmpt) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	//transport errors have no status code, they count under 0.
+	m.codes[ra.ResCode]++
+	if ra.ResErr != N {
+		m.errors[ra.ResErr]++
+	}
+	m.latency.observe(ra.ElpsdNs.Seconds())
+	cns := ra.ElpsdCorrectedNs
+	if cns < ra.ElpsdNs {
+		cns = ra.ElpsdNs
+	}
+	m.corrected.observe(cns.Seconds())
+	m.bytesRead += ra.ResBytes
+	m.bytesWritten += ra.ReqBytes
+}
+
+func (p *P0d) initMetricsServer() {
+	if p.Config.Exec.MetricsListen == N {
+		return
+	}
+	l, e := net.Listen("tcp", p.Config.Exec.MetricsListen)
+	if e != nil {
+		p.logf("%v", Red(fmt.Sprintf("unable to serve metrics on %s: %s", p.Config.Exec.MetricsListen, e.Error())))
+		return
+	}
+	p.metrics = NewMetrics()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, p.serveMetrics)
+	p.metricsServer = &http.Server{Handler: mux}
+	go p.metricsServer.Serve(l)
+	p.logf("serving metrics on %s", Yellow("http://"+l.Addr().String()+metricsPath))
+}
+
+func (p *P0d) stopMetricsServer() {
+	if p.metricsServer != nil {
+		p.metricsServer.Close()
+	}
+}
+
+func (p *P0d) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	p.writeMetrics(bw)
+}
+
+func (p *P0d) writeMetrics(w *bufio.Writer) {
+	m := p.metrics
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP p0d_requests_total HTTP requests by response code, 0 for transport errors.\n")
+	fmt.Fprintf(w, "# TYPE p0d_requests_total counter\n")
+	codes := make([]int, 0, len(m.codes))
+	for c := range m.codes {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		fmt.Fprintf(w, "p0d_requests_total{code=\"%d\"} %d\n", c, m.codes[c])
+	}
+
+	fmt.Fprintf(w, "# HELP p0d_request_errors_total HTTP requests that failed with a transport error, by type.\n")
+	fmt.Fprintf(w, "# TYPE p0d_request_errors_total counter\n")
+	errs := make([]string, 0, len(m.errors))
+	for e := range m.errors {
+		errs = append(errs, e)
+	}
+	sort.Strings(errs)
+	for _, e := range errs {
+		fmt.Fprintf(w, "p0d_request_errors_total{type=\"%s\"} %d\n", escapeLabel(e), m.errors[e])
+	}
+
+	fmt.Fprintf(w, "# HELP p0d_request_duration_seconds HTTP roundtrip latency.\n")
+	fmt.Fprintf(w, "# TYPE p0d_request_duration_seconds histogram\n")
+	m.latency.write(w, "p0d_request_duration_seconds")
+
+	fmt.Fprintf(w, "# HELP p0d_request_corrected_duration_seconds HTTP roundtrip latency from the intended send time.\n")
+	fmt.Fprintf(w, "# TYPE p0d_request_corrected_duration_seconds histogram\n")
+	m.corrected.write(w, "p0d_request_corrected_duration_seconds")
+
+	fmt.Fprintf(w, "# HELP p0d_read_bytes_total Bytes read from responses.\n")
+	fmt.Fprintf(w, "# TYPE p0d_read_bytes_total counter\n")
+	fmt.Fprintf(w, "p0d_read_bytes_total %d\n", m.bytesRead)
+
+	fmt.Fprintf(w, "# HELP p0d_written_bytes_total Bytes written in requests.\n")
+	fmt.Fprintf(w, "# TYPE p0d_written_bytes_total counter\n")
+	fmt.Fprintf(w, "p0d_written_bytes_total %d\n", m.bytesWritten)
+
+	fmt.Fprintf(w, "# HELP p0d_dropped_requests_total Requests the rate scheduler could not send on time.\n")
+	fmt.Fprintf(w, "# TYPE p0d_dropped_requests_total counter\n")
+	fmt.Fprintf(w, "p0d_dropped_requests_total %d\n", atomic.LoadInt64(&p.ReqStats.SumDroppedReqAtmpts))
+
+	fmt.Fprintf(w, "# HELP p0d_open_connections Open TCP connections to the target.\n")
+	fmt.Fprintf(w, "# TYPE p0d_open_connections gauge\n")
+	fmt.Fprintf(w, "p0d_open_connections %d\n", p.getOSOpenConns().OpenConns)
+
+	fmt.Fprintf(w, "# HELP p0d_concurrency Configured maximum concurrent TCP connections.\n")
+	fmt.Fprintf(w, "# TYPE p0d_concurrency gauge\n")
+	fmt.Fprintf(w, "p0d_concurrency %d\n", p.Config.Exec.Concurrency)
+
+	fmt.Fprintf(w, "# HELP p0d_phase Current phase of the test, 1 for the current phase.\n")
+	fmt.Fprintf(w, "# TYPE p0d_phase gauge\n")
+	for _, tp := range timerPhases {
+		v := 0
+		if p.isTimerPhase(tp) {
+			v = 1
+		}
+		fmt.Fprintf(w, "p0d_phase{phase=\"%s\"} %d\n", timerPhaseNames[tp], v)
+	}
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
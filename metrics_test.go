@@ -0,0 +1,63 @@
+package p0d
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeMetrics(t *testing.T) {
+	cfg := Config{Exec: Exec{Concurrency: 8}}
+	p := P0d{Config: cfg, ReqStats: NewReqStats(cfg), metrics: NewMetrics(), Time: Time{Phase: Main}}
+
+	p.metrics.observe(ReqAtmpt{ResCode: 200, ElpsdNs: 3 * time.Millisecond, ReqBytes: 100, ResBytes: 1000})
+	p.metrics.observe(ReqAtmpt{ResCode: 200, ElpsdNs: 30 * time.Millisecond, ElpsdCorrectedNs: 2 * time.Second,
+		ReqBytes: 100, ResBytes: 1000})
+	p.metrics.observe(ReqAtmpt{ResCode: 0, ResErr: "connection \"refused\"", ElpsdNs: time.Millisecond})
+
+	w := httptest.NewRecorder()
+	p.serveMetrics(w, httptest.NewRequest("GET", metricsPath, nil))
+	out := w.Body.String()
+
+	want := []string{
+		`p0d_requests_total{code="200"} 2`,
+		`p0d_requests_total{code="0"} 1`,
+		`p0d_request_errors_total{type="connection \"refused\""} 1`,
+		`p0d_request_duration_seconds_bucket{le="0.001"} 1`,
+		`p0d_request_duration_seconds_bucket{le="0.005"} 2`,
+		`p0d_request_duration_seconds_bucket{le="0.05"} 3`,
+		`p0d_request_duration_seconds_bucket{le="+Inf"} 3`,
+		`p0d_request_duration_seconds_count 3`,
+		`p0d_request_corrected_duration_seconds_bucket{le="1"} 2`,
+		`p0d_read_bytes_total 2000`,
+		`p0d_written_bytes_total 200`,
+		`p0d_open_connections 0`,
+		`p0d_concurrency 8`,
+		`p0d_phase{phase="main"} 1`,
+		`p0d_phase{phase="rampup"} 0`,
+	}
+	for _, l := range want {
+		if !strings.Contains(out, l+"\n") {
+			t.Errorf("metrics should contain %v", l)
+		}
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Error("metrics should use prometheus text format")
+	}
+}
+
+func TestInitMetricsServer(t *testing.T) {
+	p := P0d{Config: Config{Exec: Exec{MetricsListen: "127.0.0.1:0"}}}
+	p.initMetricsServer()
+	defer p.stopMetricsServer()
+	if p.metrics == nil || p.metricsServer == nil {
+		t.Error("metrics server should be running")
+	}
+
+	p2 := P0d{}
+	p2.initMetricsServer()
+	if p2.metrics != nil {
+		t.Error("metrics server should be off without metricsListen")
+	}
+}
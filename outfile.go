@@ -0,0 +1,117 @@
+package p0d
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"os"
+	"time"
+)
+
+type outFileReporter struct {
+	p       *P0d
+	f       *os.File
+	jsonl   bool
+	buckets int
+}
+
+func (p *P0d) initOutFile() Reporter {
+	if len(p.Output) == 0 {
+		return nil
+	}
+	w := &outFileReporter{p: p, jsonl: p.isJsonl()}
+	p.AddReporter(w)
+	return w
+}
+
+func (w *outFileReporter) OnStart(cfg Config) {
+	var oe error
+	w.f, oe = os.Create(w.p.Output)
+	if oe != nil {
+		w.p.outFileCheckWrite(oe)
+		return
+	}
+	//json lines need no opening bracket, each record stands on its own.
+	if w.jsonl {
+		return
+	}
+	w.write([]byte("["))
+}
+
+func (w *outFileReporter) OnLog(msg string) {}
+
+func (w *outFileReporter) OnPhase(phase TimerPhase) {}
+
+func (w *outFileReporter) OnAttempt(ra ReqAtmpt) {
+	//only sample a subset of requests, traced ones carry their ids so they can be matched up.
+	if ra.logged {
+		if w.jsonl {
+			w.record(RecordAttempt, ra.Stop, ra)
+			return
+		}
+		j, je := json.MarshalIndent(ra, "", "  ")
+		w.p.outFileCheckWrite(je)
+		w.write(append(j, ",\n"...))
+	}
+}
+
+func (w *outFileReporter) OnSnapshot(s *ReqStats) {
+	if !w.jsonl {
+		return
+	}
+	for _, b := range newBuckets(s, &w.buckets) {
+		w.record(RecordSnapshot, b.Start.Add(b.ElpsdNs), b)
+	}
+}
+
+func (w *outFileReporter) OnFinish(p *P0d) {
+	if w.f == nil {
+		return
+	}
+	defer w.f.Close()
+	p.logf("finalizing out file '%s'", Yellow(p.Output))
+	if w.jsonl {
+		w.OnSnapshot(p.ReqStats)
+		w.record(RecordSummary, p.Time.Stop, p)
+		return
+	}
+	j, je := json.MarshalIndent(p, "", "  ")
+	p.outFileCheckWrite(je)
+	w.write(append(j, ']'))
+}
+
+func (w *outFileReporter) record(typ string, now time.Time, data any) {
+	//one compact record per line and per write, so a crash never leaves more than the last line broken.
+	j, je := json.Marshal(Record{Type: typ, Time: now, Data: data})
+	w.p.outFileCheckWrite(je)
+	w.write(append(j, '\n'))
+}
+
+func (w *outFileReporter) write(b []byte) {
+	if w.f == nil {
+		return
+	}
+	_, we := w.f.Write(b)
+	w.p.outFileCheckWrite(we)
+}
+
+func (p *P0d) outFileCheckWrite(e error) {
+	if e != nil {
+		//the run isn't worth much without its output, stop it and hand the error back.
+		if p.outErr == nil {
+			p.outErr = e
+			p.logf("%v", Red(fmt.Sprintf("unable to write to output file %s: %s", p.Output, e.Error())))
+		}
+		p.interruptRun()
+	}
+}
+
+func newBuckets(s *ReqStats, seen *int) []*Bucket {
+	//series buckets are only ever appended, hand out what we haven't seen yet.
+	if *seen >= len(s.Series) {
+		return nil
+	}
+	b := s.Series[*seen:]
+	*seen = len(s.Series)
+	return b
+}
@@ -4,9 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"github.com/axiomhq/variance"
 	"github.com/google/uuid"
 	"github.com/gosuri/uilive"
 	"github.com/hako/durafmt"
@@ -18,6 +16,7 @@ import (
 	"mime/multipart"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
 	"os"
@@ -49,22 +48,43 @@ type P0d struct {
 	OS          OS
 	ReqStats    *ReqStats
 	Output      string
+	Report      string
 	Interrupted bool
-
-	client          map[int]*http.Client
-	sampleConn      net.Conn
-	outFile         *os.File
-	liveWriters     []io.Writer
-	bar             *ProgressBar
-	interrupt       chan os.Signal
-	stopLiveWriters chan struct{}
-	stopThreads     []chan struct{}
+	AbortReason string
+	Verdict     *Verdict
+
+	client         map[int]*http.Client
+	sampleConn     net.Conn
+	liveWriters    []io.Writer
+	bar            *ProgressBar
+	interrupt      chan os.Signal
+	stopping       chan struct{}
+	stopOnce       sync.Once
+	stopThreads    []chan struct{}
+	stopSched      chan struct{}
+	exhausted      chan struct{}
+	activeThreads  int
+	threadsLock    sync.Mutex
+	resize         chan int
+	stage          chan int
+	runningThreads []int32
+	abortWindow    *ReqStats
+	abortBreaches  []int
+	metrics        *Metrics
+	metricsServer  *http.Server
+	pusher         *pusher
+	spanExporter   *spanExporter
+	reqLog         *reqLog
+	reporters      []Reporter
+	phases         chan TimerPhase
+	outErr         error
 }
 
 type Time struct {
 	Start time.Time
 	Stop  time.Time
 	Phase TimerPhase
+	Stage string
 }
 
 type OS struct {
@@ -105,13 +125,28 @@ const (
 )
 
 type ReqAtmpt struct {
-	Start    time.Time
-	Stop     time.Time
-	ElpsdNs  time.Duration
-	ReqBytes int64
-	ResCode  int
-	ResBytes int64
-	ResErr   string
+	Start            time.Time
+	Intended         time.Time
+	Stop             time.Time
+	ElpsdNs          time.Duration
+	ElpsdCorrectedNs time.Duration
+	ReqName          string
+	ReqBytes         int64
+	ResCode          int
+	ResBytes         int64
+	ResErr           string
+	DNSNs            time.Duration
+	ConnectNs        time.Duration
+	TLSNs            time.Duration
+	TTFBNs           time.Duration
+	DownloadNs       time.Duration
+	ConnReused       bool
+	Slot             int
+	TraceID          string
+	SpanID           string
+	AssertFails      []string
+	Journey          *JourneyAtmpt
+	logged           bool
 }
 
 func initStopThreads(cfg Config) []chan struct{} {
@@ -129,6 +164,20 @@ func interruptChannel() chan os.Signal {
 	return sigs
 }
 
+func (p *P0d) interruptRun() {
+	select {
+	case p.interrupt <- syscall.SIGINT:
+	default:
+	}
+}
+
+func (p *P0d) stopRun() {
+	//ends the run early like its duration would, so thresholds still give a verdict.
+	p.stopOnce.Do(func() {
+		close(p.stopping)
+	})
+}
+
 func NewP0dWithValues(c int, d int, u string, h string, o string, s bool) *P0d {
 	hv, _ := strconv.ParseFloat(h, 32)
 
@@ -146,21 +195,40 @@ func NewP0dWithValues(c int, d int, u string, h string, o string, s bool) *P0d {
 			SkipInetTest:    s,
 		},
 	}
-	cfg = *cfg.validate()
-
-	_, ul := getUlimit()
-
-	return NewP0d(cfg, ul, o, d, interruptChannel())
+	p, e := NewP0dFromConfig(cfg, o)
+	exitOnConfigError(e)
+	return p.withConsole()
 }
 
 func NewP0dFromFile(f string, o string) *P0d {
-	cfg := loadConfigFromFile(f)
-	cfg.File = f
-	cfg = cfg.validate()
+	p, e := NewP0dFromConfigFile(f, o)
+	exitOnConfigError(e)
+	return p.withConsole()
+}
 
+func NewP0dFromConfigFile(f string, o string) (*P0d, error) {
+	cfg, e := LoadConfig(f)
+	if e != nil {
+		return nil, e
+	}
+	return NewP0dFromConfig(*cfg, o)
+}
+
+func NewP0dFromConfig(cfg Config, o string) (*P0d, error) {
+	if e := cfg.Validate(); e != nil {
+		return nil, e
+	}
 	_, ul := getUlimit()
 
-	return NewP0d(*cfg, ul, o, cfg.Exec.DurationSeconds, interruptChannel())
+	//embedded runs are stopped with their context, we leave signals to whoever embeds us.
+	return NewP0d(cfg, ul, o, cfg.Exec.DurationSeconds, make(chan os.Signal, 1)), nil
+}
+
+func (p *P0d) withConsole() *P0d {
+	//the cli stops on CTRL+C and shows what's going on
+	p.interrupt = interruptChannel()
+	p.AddReporter(NewConsoleReporter(p))
+	return p
 }
 
 func NewP0d(cfg Config, ulimit int64, outputFile string, durationSecs int, interrupt chan os.Signal) *P0d {
@@ -176,17 +244,13 @@ func NewP0d(cfg Config, ulimit int64, outputFile string, durationSecs int, inter
 			MaxOpenConns:    0,
 			updateLock:      sync.Mutex{},
 			InetTestAborted: false,
-			inetUlSpeedDone: make(chan struct{}),
-			inetDlSpeedDone: make(chan struct{}),
-			inetLatencyDone: make(chan struct{}),
-			inetTestError:   make(chan struct{}),
-		},
-		ReqStats: &ReqStats{
-			ErrorTypes:                   make(map[string]int),
-			Sample:                       NewSample(),
-			ElpsdAtmptLatencyNsQuantiles: NewQuantileWithCompression(500),
-			ElpsdAtmptLatencyNs:          &Welford{s: variance.New()},
+			//buffered, nobody may be watching the inet test without a console.
+			inetUlSpeedDone: make(chan struct{}, 1),
+			inetDlSpeedDone: make(chan struct{}, 1),
+			inetLatencyDone: make(chan struct{}, 1),
+			inetTestError:   make(chan struct{}, 1),
 		},
+		ReqStats:    NewReqStats(cfg),
 		Output:      outputFile,
 		Interrupted: false,
 
@@ -196,41 +260,66 @@ func NewP0d(cfg Config, ulimit int64, outputFile string, durationSecs int, inter
 			size:       30,
 			chunkProps: make([]ChunkProps, 30),
 		},
-		interrupt:       interrupt,
-		stopLiveWriters: make(chan struct{}),
-		stopThreads:     initStopThreads(cfg),
+		interrupt:   interrupt,
+		stopping:    make(chan struct{}),
+		stopThreads: initStopThreads(cfg),
+		stopSched:   make(chan struct{}, 1),
+		exhausted:   make(chan struct{}, 1),
+		resize:      make(chan int, 1),
+		stage:       make(chan int, 1),
+		//every phase is entered at most once, this never fills up.
+		phases: make(chan TimerPhase, 8),
 	}
 }
 
 func (p *P0d) StartTimeNow() {
 	now := time.Now()
 	p.Time.Start = now
-	p.ReqStats.Start = now
+	p.ReqStats.setStart(now)
 }
 
 const backspace = "\x1b[%dD"
 
-func (p *P0d) Race() {
+const liveInterval = time.Millisecond * 100
+
+type Result struct {
+	ID          string
+	Time        Time
+	ReqStats    *ReqStats
+	Interrupted bool
+	AbortReason string
+	Verdict     *Verdict
+	ExitCode    int
+}
+
+func (p *P0d) Race(ctx context.Context) (*Result, error) {
+	//cancelling the context is handled like CTRL+C, the run drains and we still get results.
+	stopCtx := context.AfterFunc(ctx, p.interruptRun)
+	defer stopCtx()
+
 	osStatsDone := make(chan struct{}, 2)
 	p.initOSStats(osStatsDone)
 	p.detectRemoteConnSettings()
-	p.initLog()
-
-	defer func() {
-		if p.outFile != nil {
-			p.outFile.Close()
-		}
-	}()
 	p.initOutFile()
+	p.onStart()
+	p.warnRatePool()
+	p.initMetricsServer()
+	defer p.stopMetricsServer()
+	p.initSpanExporter()
 
 	p.StartTimeNow()
+	p.ReqStats.openBucket(p.Time.Start)
+	p.initReqLog()
 	p.bar.updateRampStateForTimerPhase(p.Time.Start, p)
+	p.bar.markStages(p)
 
-	//init timer for rampdown trigger
+	//init timer for rampdown trigger, stages bring their own.
 	rampdown := make(chan struct{})
-	time.AfterFunc(time.Duration(p.Config.Exec.DurationSeconds-p.Config.Exec.RampSeconds)*time.Second, func() {
-		rampdown <- struct{}{}
-	})
+	if !p.Config.hasStages() {
+		time.AfterFunc(time.Duration(p.Config.Exec.DurationSeconds-p.Config.Exec.RampSeconds)*time.Second, func() {
+			rampdown <- struct{}{}
+		})
+	}
 
 	//init timer for trigger end to totalruntime and start draining
 	drainer := make(chan struct{})
@@ -246,68 +335,154 @@ func (p *P0d) Race() {
 		initReqAtmptsDone := make(chan struct{}, 2)
 		p.initReqAtmpts(initReqAtmptsDone, ras)
 
-		p.initLiveWriterFastLoop(8)
+		liveTicker := time.NewTicker(liveInterval)
+		defer liveTicker.Stop()
+
+		//abort conditions are checked every second, without any we never tick.
+		var abortTick <-chan time.Time
+		if len(p.Config.aborts) > 0 {
+			t := time.NewTicker(abortCheckInterval)
+			defer t.Stop()
+			abortTick = t.C
+			p.initAbortWindow(time.Now())
+		}
+
+		//same for pushing metrics snapshots
+		var pushTick <-chan time.Time
+		p.initPusher()
+		if p.pusher != nil {
+			t := time.NewTicker(pushInterval)
+			defer t.Stop()
+			pushTick = t.C
+		}
 
-		const prefix string = ""
-		const indent string = "  "
-		var comma = []byte(",\n")
+		//time series buckets are closed on their own interval.
+		seriesTicker := time.NewTicker(time.Duration(p.Config.Exec.SeriesSeconds) * time.Second)
+		defer seriesTicker.Stop()
 
 		drain := func() {
 			//this one log event renders the progress bar at 0 seconds remaining
 			initReqAtmptsDone <- struct{}{}
-			p.doLogLive()
+			p.onSnapshot()
 			p.Time.Stop = time.Now()
 			p.setTimerPhase(Draining)
 			//we still want to watch draining but much faster.
+			p.stopRateScheduler()
 			p.stopReqAtmptsThreads(time.Millisecond * 1)
-			p.stopLiveWriterFastLoop()
 		Drain:
 			for i := 0; i < 300; i++ {
 				if p.getOSOpenConns().OpenConns == 0 {
 					osStatsDone <- struct{}{}
 					break Drain
 				}
-				time.Sleep(time.Millisecond * 100)
-				p.doLogLive()
+				time.Sleep(liveInterval)
+				p.onSnapshot()
 			}
 			p.setTimerPhase(Drained)
 			//do this so no cur atmpts continue to be reported and all remaining decrease timers fire
 			time.Sleep(time.Millisecond * 1010)
 			atomic.StoreInt64(&p.ReqStats.CurReqAtmptsPSec, 0)
-			p.closeLiveWritersAndSummarize()
+			p.evalThresholds()
 		}
 	Main:
 		for {
 			select {
 			case <-p.interrupt:
 				//because CTRL+C is crazy and messes up our live log by two spaces
-				fmt.Fprintf(p.liveWriters[0], backspace, 2)
+				if p.liveWriters != nil && ctx.Err() == nil {
+					fmt.Fprintf(p.liveWriters[0], backspace, 2)
+				}
 				p.Interrupted = true
 				//in case of interupt we signal the inet speed test to cancel if it's still running
 				if !p.Config.Exec.SkipInetTest && !p.OS.isInetTestDone() {
-					p.OS.inetTestError <- struct{}{}
+					select {
+					case p.OS.inetTestError <- struct{}{}:
+					default:
+					}
 				}
 				drain()
 				break Main
 			case <-drainer:
 				drain()
 				break Main
+			case <-p.stopping:
+				drain()
+				break Main
+			case <-liveTicker.C:
+				p.onSnapshot()
+			case now := <-seriesTicker.C:
+				p.rollSeries(now)
+			case now := <-pushTick:
+				p.pushSnapshot(now)
+			case now := <-abortTick:
+				//the target is failing, stop hammering it.
+				if p.checkAbort(now) {
+					drain()
+					break Main
+				}
+			case <-p.exhausted:
+				//a sequence feeder ran out of rows on every slot, there is nothing left to send.
+				drain()
+				break Main
+			case i := <-p.stage:
+				p.setStage(i)
+			case n := <-p.resize:
+				if p.canResize() {
+					p.setActiveThreads(n, ras)
+				}
 			case <-rampdown:
 				p.setTimerPhase(RampDown)
-				p.stopReqAtmptsThreads(p.staggerThreadsDuration())
+				//in rate mode the scheduler ramps down the offered load, the worker pool stays up.
+				if !p.Config.isRateMode() {
+					p.stopReqAtmptsThreads(p.staggerThreadsDuration())
+				}
 			case ra := <-ras:
 				p.ReqStats.update(ra, ra.Stop, p.Config)
-				p.outFileRequestAttempt(ra, prefix, indent, comma)
+				if p.abortWindow != nil {
+					p.abortWindow.update(ra, ra.Stop, p.Config)
+				}
+				if p.metrics != nil {
+					p.metrics.observe(ra)
+				}
+				p.logReqAtmpt(ra)
+				p.onAttempt(ra)
 			}
 		}
 	}
 	p.setTimerPhase(Done)
+	p.pushSnapshot(time.Now())
+	p.stopPusher()
+	if p.pusher != nil && atomic.LoadInt64(&p.pusher.errors) > 0 {
+		p.logf("%v", Red(fmt.Sprintf("unable to push %d metrics snapshot(s) to %s", atomic.LoadInt64(&p.pusher.errors),
+			p.Config.Exec.Push.Url)))
+	}
+	p.stopSpanExporter()
+	if p.spanExporter != nil && atomic.LoadInt64(&p.spanExporter.dropped) > 0 {
+		p.logf("%v", Red(fmt.Sprintf("unable to export %d trace span(s) to %s", atomic.LoadInt64(&p.spanExporter.dropped),
+			p.Config.Exec.Tracing.Endpoint)))
+	}
 
 	osStatsDone <- struct{}{}
 	//adjust time stop for aborts
 	p.Time.Stop = time.Now()
-	p.finalizeOutFile()
-	log(Cyan("exiting").String())
+	p.ReqStats.closeBucket(p.Time.Stop, timerPhaseNames[p.Time.Phase], p.getOSOpenConns().OpenConns)
+	p.stopReqLog(p.Time.Stop)
+	p.onFinish()
+	p.writeReport()
+	p.logf("%v", Cyan("exiting"))
+	return p.result(), p.outErr
+}
+
+func (p *P0d) result() *Result {
+	return &Result{
+		ID:          p.ID,
+		Time:        p.Time,
+		ReqStats:    p.ReqStats,
+		Interrupted: p.Interrupted,
+		AbortReason: p.AbortReason,
+		Verdict:     p.Verdict,
+		ExitCode:    p.ExitCode(),
+	}
 }
 
 const defMsg = "not detected"
@@ -355,7 +530,16 @@ func (p *P0d) detectRemoteConnSettings() {
 }
 
 func (p *P0d) initReqAtmpts(done chan struct{}, ras chan ReqAtmpt) {
+	if p.Config.hasStages() {
+		p.initStageReqAtmpts(done, ras)
+		return
+	}
+	if p.Config.isRateMode() {
+		p.initRateReqAtmpts(done, ras)
+		return
+	}
 
+	p.runningThreads = make([]int32, p.Config.Exec.Concurrency)
 	//don't block because execution continues on to live updates
 	go func() {
 		bd := false
@@ -368,7 +552,7 @@ func (p *P0d) initReqAtmpts(done chan struct{}, ras chan ReqAtmpt) {
 				break RampUp
 			default:
 				//stagger the initialisation so we can watch ramp up live.
-				go p.doReqAtmpts(i, ras, p.stopThreads[i])
+				p.setActiveThreads(i+1, ras)
 				if p.Config.Exec.Concurrency > 1 && i < p.Config.Exec.Concurrency-1 {
 					time.Sleep(p.staggerThreadsDuration())
 				}
@@ -389,6 +573,27 @@ func (p *P0d) initReqAtmpts(done chan struct{}, ras chan ReqAtmpt) {
 	}()
 }
 
+func (p *P0d) initRateReqAtmpts(done chan struct{}, ras chan ReqAtmpt) {
+	p.setTimerPhase(RampUp)
+
+	//the whole pool starts at once, the scheduler is in charge of ramping up the offered load.
+	sched := make(chan time.Time)
+	for i := 0; i < p.Config.Exec.Concurrency; i++ {
+		go p.doRateReqAtmpts(i, ras, p.stopThreads[i], sched)
+	}
+	p.initRateScheduler(p.stopSched, sched)
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(time.Duration(p.Config.Exec.RampSeconds) * time.Second):
+			if p.Time.Phase < Main {
+				p.setTimerPhase(Main)
+			}
+		}
+	}()
+}
+
 func (p *P0d) staggerThreadsDuration() time.Duration {
 	return time.Duration(
 		float64(time.Second) * (float64(p.Config.Exec.RampSeconds) / float64(p.Config.Exec.Concurrency)),
@@ -396,6 +601,10 @@ func (p *P0d) staggerThreadsDuration() time.Duration {
 }
 
 func (p *P0d) doReqAtmpts(i int, ras chan<- ReqAtmpt, done <-chan struct{}) {
+	var intended time.Time
+	if p.Config.Exec.SpacingMillis > 0 {
+		intended = time.Now()
+	}
 ReqAtmpt:
 	for {
 		select {
@@ -404,77 +613,163 @@ ReqAtmpt:
 		default:
 		}
 
-		//introduce artifical request latency
+		//introduce artifical request latency. spacing is a timetable for this worker, if the last response was
+		//slower than spacing we are already late, don't sleep and let corrected latency account for the wait.
 		if p.Config.Exec.SpacingMillis > 0 {
-			time.Sleep(time.Duration(p.Config.Exec.SpacingMillis) * time.Millisecond)
+			intended = intended.Add(time.Duration(p.Config.Exec.SpacingMillis) * time.Millisecond)
+			if d := time.Until(intended); d > 0 {
+				time.Sleep(d)
+			}
 		}
 
-		ra := ReqAtmpt{
-			Start: time.Now(),
-		}
-		p.bar.updateRampStateForTimerPhase(ra.Start, p)
-
-		req := p.scaffoldHttpReq()
-
-		//measure for size before sending. We don't set content length, go does that internally
-		bq, _ := httputil.DumpRequest(req, true)
-		ra.ReqBytes = int64(len(bq))
-		_ = bq
-
-		//do the work and dump the response for size
-		res, e := p.client[i].Do(req)
-		if res != nil {
-			ra.ResCode = res.StatusCode
-			b, _ := httputil.DumpResponse(res, true)
-			ra.ResBytes = int64(len(b))
-			_ = b
-			res.Body.Close()
+		//and report back. if there's no more data to send, wait to be stopped.
+		if !p.doIteration(i, intended, ras) {
+			<-done
+			break ReqAtmpt
 		}
+	}
+}
 
+func (p *P0d) doIteration(i int, intended time.Time, ras chan<- ReqAtmpt) bool {
+	if p.Config.isScenario() {
+		return p.doJourneyAtmpt(i, intended, ras)
+	}
+	ra, ok := p.doReqAtmpt(i, intended)
+	if ok {
+		ras <- ra
+	}
+	return ok
+}
+
+func (p *P0d) doReqAtmpt(i int, intended time.Time) (ReqAtmpt, bool) {
+	tmpl := p.Config.pickReq()
+	vars, ok := tmpl.feed(i, nil)
+	if !ok {
+		p.feederExhausted(tmpl.Feeder, i)
+		return ReqAtmpt{}, false
+	}
+	return p.doReqAtmptFor(i, intended, tmpl, vars, nil), true
+}
+
+func (p *P0d) doReqAtmptFor(i int, intended time.Time, tmpl *Req, vars map[string]string,
+	onRes func(res *http.Response)) ReqAtmpt {
+	ra := ReqAtmpt{
+		Start:    time.Now(),
+		Intended: intended,
+		Slot:     i,
+		//decide once whether -O logs this attempt, so tracing can follow the same choice.
+		logged: len(p.Output) > 0 && rand.Float64() < p.Config.Exec.LogSampling,
+	}
+	//without a timetable we intend to send right now.
+	if ra.Intended.IsZero() {
+		ra.Intended = ra.Start
+	}
+	p.bar.updateRampStateForTimerPhase(ra.Start, p)
+
+	ra.ReqName = tmpl.Name
+	req, e := p.scaffoldHttpReqFor(tmpl, vars)
+	if e != nil {
+		//don't send literal template text to the target, count it against this attempt instead.
 		ra.Stop = time.Now()
 		ra.ElpsdNs = ra.Stop.Sub(ra.Start)
+		ra.ElpsdCorrectedNs = ra.Stop.Sub(ra.Intended)
+		ra.ResErr = e.Error()
+		p.bar.markError(ra.Stop, p)
+		return ra
+	}
+	pt := &phaseTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), pt.clientTrace()))
+	p.traceReq(req, &ra)
+
+	//measure for size before sending. We don't set content length, go does that internally
+	bq, _ := httputil.DumpRequest(req, true)
+	ra.ReqBytes = int64(len(bq))
+	_ = bq
+
+	//do the work and dump the response for size
+	res, e := p.client[i].Do(req)
+	if res != nil {
+		ra.ResCode = res.StatusCode
+		b, _ := httputil.DumpResponse(res, true)
+		ra.ResBytes = int64(len(b))
+		_ = b
+	}
 
-		//report on errors
-		if e != nil {
-			em := N
-		Mapping:
-			for ek, ev := range errorMapping {
-				if strings.Contains(e.Error(), ek) {
-					em = ev
-					break Mapping
-				}
-			}
-			if em == N {
-				em = e.Error()
-			}
-			ra.ResErr = em
-		}
+	ra.Stop = time.Now()
+	ra.ElpsdNs = ra.Stop.Sub(ra.Start)
+	ra.ElpsdCorrectedNs = ra.Stop.Sub(ra.Intended)
+	pt.record(&ra)
 
-		if len(ra.ResErr) > 0 {
-			p.bar.markError(ra.Stop, p)
+	//dumping restores the body, so we can look at the response outside of the measured time.
+	if res != nil {
+		if p.Config.Res.Assertions.active() {
+			ra.AssertFails = p.Config.Res.assert(res, ra.ElpsdNs)
 		}
+		if onRes != nil {
+			onRes(res)
+		}
+		res.Body.Close()
+	}
 
-		//null this aggressively
-		req = nil
+	//report on errors
+	if e != nil {
+		em := N
+	Mapping:
+		for ek, ev := range errorMapping {
+			if strings.Contains(e.Error(), ek) {
+				em = ev
+				break Mapping
+			}
+		}
+		if em == N {
+			em = e.Error()
+		}
+		ra.ResErr = em
+	}
 
-		//and report back
-		ras <- ra
+	if len(ra.ResErr) > 0 {
+		p.bar.markError(ra.Stop, p)
 	}
+	p.exportSpans(req, ra, pt)
+
+	//null this aggressively
+	req = nil
+
+	return ra
 }
 
 func (p *P0d) scaffoldHttpReq() *http.Request {
+	//the probe isn't part of the run, so it mustn't advance {{seq}} for real traffic.
+	if p.Config.seq != nil {
+		defer atomic.StoreInt64(p.Config.seq, atomic.LoadInt64(p.Config.seq))
+	}
+	req, _ := p.scaffoldHttpReqFor(&p.Config.Req, nil)
+	return req
+}
+
+func (p *P0d) scaffoldHttpReqFor(r *Req, vars map[string]string) (*http.Request, error) {
 	var body io.Reader
 
+	//we keep going on a template error so the request is complete, the first one is reported.
+	var re error
+	render := func(s string) string {
+		v, e := r.render(s, vars)
+		if e != nil && re == nil {
+			re = e
+		}
+		return v
+	}
+
 	//multipartwriter adds a boundary
 	var mpContentType string
 
 	//needs to decide between url encoded, multipart form data and everything else
-	switch p.Config.Req.ContentType {
+	switch r.ContentType {
 	case applicationXWWWFormUrlEncoded:
 		data := url.Values{}
-		for _, fd := range p.Config.Req.FormData {
+		for _, fd := range r.FormData {
 			for k, v := range fd {
-				data.Add(k, v)
+				data.Add(k, render(v))
 			}
 		}
 		body = strings.NewReader(data.Encode())
@@ -482,14 +777,14 @@ func (p *P0d) scaffoldHttpReq() *http.Request {
 		var b bytes.Buffer
 		mpw := multipart.NewWriter(&b)
 
-		for _, fd := range p.Config.Req.FormData {
+		for _, fd := range r.FormData {
 			for k, v := range fd {
 				if strings.HasPrefix(k, AT) {
 					fw, _ := mpw.CreateFormFile(k, v)
 					mpContentType = mpw.FormDataContentType()
-					io.Copy(fw, bytes.NewReader(p.Config.Req.FormDataFiles[k]))
+					io.Copy(fw, bytes.NewReader(r.FormDataFiles[k]))
 				} else {
-					mpw.WriteField(k, v)
+					mpw.WriteField(k, render(v))
 				}
 			}
 		}
@@ -499,21 +794,21 @@ func (p *P0d) scaffoldHttpReq() *http.Request {
 	case applicationJson:
 		fallthrough
 	default:
-		body = strings.NewReader(p.Config.Req.Body)
+		body = strings.NewReader(render(r.Body))
 	}
 
-	req, _ := http.NewRequest(p.Config.Req.Method,
-		p.Config.Req.Url,
+	req, _ := http.NewRequest(r.Method,
+		render(r.Url),
 		body)
 
 	//set headers from config
-	if len(p.Config.Req.Headers) > 0 {
-		for _, h := range p.Config.Req.Headers {
+	if len(r.Headers) > 0 {
+		for _, h := range r.Headers {
 			for k, v := range h {
 				if k == ct && v == multipartFormdata {
 					req.Header.Set(k, mpContentType)
 				} else {
-					req.Header.Add(k, v)
+					req.Header.Add(k, render(v))
 				}
 			}
 		}
@@ -522,7 +817,7 @@ func (p *P0d) scaffoldHttpReq() *http.Request {
 	//set user agent
 	req.Header.Set(ua, vs)
 
-	return req
+	return req, re
 }
 
 func (p *P0d) stopReqAtmptsThreads(staggerThreadsDuration time.Duration) {
@@ -540,9 +835,13 @@ func (p *P0d) stopReqAtmptsThreads(staggerThreadsDuration time.Duration) {
 	}()
 }
 
-func (p *P0d) initLiveWriterFastLoop(n int) {
-	//start live logging
+func (p *P0d) stopRateScheduler() {
+	if p.Config.isRateMode() {
+		p.stopSched <- struct{}{}
+	}
+}
 
+func (p *P0d) initLiveWriters(n int) {
 	l0 := uilive.New()
 	//this prevents the writer from flushing inbetween lines. we flush manually after each iteration
 	l0.RefreshInterval = time.Hour * 24 * 30
@@ -553,47 +852,7 @@ func (p *P0d) initLiveWriterFastLoop(n int) {
 	for i := 0; i <= n; i++ {
 		live = append(live, live[0].(*uilive.Writer).Newline())
 	}
-
-	//do this before setting off goroutines
 	p.liveWriters = live
-
-	//now start live logging
-	go func(done chan struct{}) {
-	LiveWriters:
-		for {
-			select {
-			case <-done:
-				break LiveWriters
-			default:
-				p.doLogLive()
-				time.Sleep(time.Millisecond * 100)
-			}
-		}
-	}(p.stopLiveWriters)
-}
-
-func (p *P0d) stopLiveWriterFastLoop() {
-	p.stopLiveWriters <- struct{}{}
-	close(p.stopLiveWriters)
-}
-
-func (p *P0d) closeLiveWritersAndSummarize() {
-	//call final log manually to prevent differences between summary and what's on screen in live log.
-	p.doLogLive()
-	p.liveWriters[0].(*uilive.Writer).Stop()
-	p.logSummary()
-}
-
-func (p *P0d) finalizeOutFile() {
-	if len(p.Output) > 0 {
-		log("finalizing out file '%s'", Yellow(p.Output))
-		j, je := json.MarshalIndent(p, "", "  ")
-		p.outFileCheckWrite(je)
-		_, we := p.outFile.Write(j)
-		p.outFileCheckWrite(we)
-		_, we = p.outFile.Write([]byte("]"))
-		p.outFileCheckWrite(we)
-	}
 }
 
 func (p *P0d) initLog() {
@@ -712,6 +971,25 @@ func (p *P0d) initLog() {
 		Yellow(durafmt.Parse(time.Duration(p.Config.Exec.DurationSeconds)*time.Second).LimitFirstN(2).String()))
 
 	slog("set max concurrent TCP conn(s): %s", Yellow(FGroup(int64(p.Config.Exec.Concurrency))))
+	for _, s := range p.Config.Exec.Stages {
+		target := Yellow(FGroup(int64(s.Concurrency))).String() + Yellow(" TCP conn(s)").String()
+		if p.Config.isRateMode() {
+			target = Yellow(FGroup(int64(s.Rate))).String() + Yellow(perSecondMsg).String()
+		}
+		slog("set %s: %s to %s",
+			Yellow(s.Name),
+			Yellow(durafmt.Parse(time.Duration(s.DurationSeconds)*time.Second).LimitFirstN(2).String()),
+			target)
+	}
+	for _, t := range p.Config.thresholds {
+		slog("set threshold: %s", Yellow(t.raw))
+	}
+	for _, a := range p.Config.aborts {
+		slog("set abort condition: %s", Yellow(a.raw))
+	}
+	if p.Config.isRateMode() {
+		slog("set constant arrival rate: %s%s", Yellow(FGroup(int64(p.Config.Exec.Rate))), Yellow(perSecondMsg))
+	}
 	slog("set network dial timeout (inc. TLS handshake): %s",
 		Yellow(durafmt.Parse(time.Duration(p.Config.Exec.DialTimeoutSeconds)*time.Second).LimitFirstN(2).String()))
 	if p.Config.Exec.SpacingMillis > 0 {
@@ -725,7 +1003,28 @@ func (p *P0d) initLog() {
 	slog("set preferred http version: %s ",
 		Yellow(fmt.Sprintf("%.1f", p.Config.Exec.HttpVersion)),
 	)
-	fmt.Printf(timefmt("set URL %s (%s)"), Yellow(p.Config.Req.Url), Yellow(p.Config.Req.Method))
+	if p.Config.isScenario() {
+		for _, st := range p.Config.Scenario.Steps {
+			fmt.Printf(timefmt("set step [%s] URL %s (%s)"), Yellow(st.Name), Yellow(st.Req.Url), Yellow(st.Req.Method))
+		}
+	} else if len(p.Config.Reqs) > 0 {
+		sum := 0
+		for _, r := range p.Config.Reqs {
+			sum += r.Weight
+		}
+		for _, r := range p.Config.Reqs {
+			fmt.Printf(timefmt("set req [%s] URL %s (%s) weight: %s"), Yellow(r.Name), Yellow(r.Url), Yellow(r.Method),
+				Yellow(fmt.Sprintf("%.2f%%", 100*float64(r.Weight)/float64(sum))))
+		}
+	} else {
+		fmt.Printf(timefmt("set URL %s (%s)"), Yellow(p.Config.Req.Url), Yellow(p.Config.Req.Method))
+	}
+	for _, r := range p.Config.reqs() {
+		if r.Feeder != nil {
+			slog("set feeder %s: %s rows (%s, %s)", Yellow(r.Feeder.File), Yellow(FGroup(int64(len(r.Feeder.rows)))),
+				Yellow(r.Feeder.Mode), Yellow(r.Feeder.Sharing))
+		}
+	}
 
 	tv := ""
 	if p.ReqStats.Sample.TLSVersion == defMsg {
@@ -763,13 +1062,18 @@ var draining = Cyan(" (draining) ").String()
 var drained = Cyan(" (drained)").String()
 
 const httpReqSMsg = "HTTP req: %s"
+const offeredMsg = " offered: %s%s dropped/late: %s"
+const journeyMsg = " journeys: %s success: %s"
 const roundtripThroughputMsg = "roundtrip throughput: %s%s mean: %s%s max: %s%s"
-const pctRoundTripLatency = "roundtrip latency pct10: %s pct50: %s pct90: %s pct99: %s"
+const pctRoundTripLatency = "roundtrip latency pct10: %s pct50: %s pct90: %s pct99: %s corrected pct50: %s pct90: %s pct99: %s"
+const phasesMsg = "roundtrip phases pct50 dns: %s connect: %s tls: %s ttfb: %s download: %s conn reused: %s"
 const readthroughputMsg = "read throughput: %s%s mean: %s%s max: %s%s sum: %s"
 const writeThroughputMsg = "write throughput: %s%s mean: %s%s max: %s%s sum: %s"
 const matchingResponseCodesMsg = "matching HTTP response codes: %v"
+const failedAssertionsMsg = " failed assertions: %v"
 const transportErrorsMsg = "transport errors: %v"
 const maxMsg = " max: "
+const stageMsg = " stage: "
 const perSecondMsg = "/s"
 
 func (p *P0d) doLogLive() {
@@ -798,6 +1102,10 @@ func (p *P0d) doLogLive() {
 
 	connMsg += maxMsg
 	connMsg += Magenta(FGroup(int64(p.OS.MaxOpenConns))).String()
+	if len(p.Time.Stage) > 0 {
+		connMsg += stageMsg
+		connMsg += Cyan(p.Time.Stage).String()
+	}
 
 	fmt.Fprintf(lw[i], timefmt(connMsg),
 		Cyan(FGroup(int64(oss.OpenConns))),
@@ -806,8 +1114,31 @@ func (p *P0d) doLogLive() {
 
 	i++
 
-	fmt.Fprintf(lw[i], timefmt(httpReqSMsg),
-		Cyan(FGroup(int64(p.ReqStats.ReqAtmpts))))
+	reqMsg := fmt.Sprintf(timefmt(httpReqSMsg), Cyan(FGroup(int64(p.ReqStats.ReqAtmpts))))
+	if p.Config.isRateMode() {
+		dr := fmt.Sprintf("%s (%s%%)",
+			FGroup(atomic.LoadInt64(&p.ReqStats.SumDroppedReqAtmpts)),
+			fmt.Sprintf("%.2f", math.Ceil(float64(p.ReqStats.PctDroppedReqAtmpts*100))/100))
+		var drc Value
+		if atomic.LoadInt64(&p.ReqStats.SumDroppedReqAtmpts) > 0 {
+			drc = Red(dr)
+		} else {
+			drc = Cyan(dr)
+		}
+		reqMsg = strings.TrimSuffix(reqMsg, "\n") + fmt.Sprintf(offeredMsg,
+			Cyan(FGroup(int64(p.scheduleRate(elpsd)))),
+			Cyan(perSecondMsg),
+			drc) + "\n"
+	}
+	if p.Config.isScenario() {
+		js := fmt.Sprintf("%s (%s%%)",
+			FGroup(int64(p.ReqStats.SumJourneySuccess)),
+			fmt.Sprintf("%.2f", math.Floor(float64(p.ReqStats.PctJourneySuccess*100))/100))
+		reqMsg = strings.TrimSuffix(reqMsg, "\n") + fmt.Sprintf(journeyMsg,
+			Cyan(FGroup(p.ReqStats.JourneyAtmpts)),
+			Cyan(js)) + "\n"
+	}
+	fmt.Fprint(lw[i], reqMsg)
 
 	i++
 
@@ -821,24 +1152,26 @@ func (p *P0d) doLogLive() {
 
 	i++
 
-	convertToMs := func(q *Quantile, v float64) string {
-		qv := q.Quantile(v)
-		if math.IsNaN(qv) {
-			qv = 0
-		}
-		c := time.Duration(int64(qv))
-		if c.Milliseconds() == 0 {
-			return FGroup(c.Microseconds()) + "μs"
-		} else {
-			return FGroup(c.Milliseconds()) + "ms"
-		}
-	}
-
 	fmt.Fprintf(lw[i], timefmt(pctRoundTripLatency),
-		Cyan(convertToMs(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.1)),
-		Cyan(convertToMs(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.5)),
-		Cyan(convertToMs(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.9)),
-		Cyan(convertToMs(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.99)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.1)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.5)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.9)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptLatencyNsQuantiles, 0.99)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptCorrectedLatencyNsQuantiles, 0.5)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptCorrectedLatencyNsQuantiles, 0.9)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdAtmptCorrectedLatencyNsQuantiles, 0.99)),
+	)
+
+	i++
+	fmt.Fprintf(lw[i], timefmt(phasesMsg),
+		Cyan(fmtLatency(p.ReqStats.ElpsdDNSNsQuantiles, 0.5)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdConnectNsQuantiles, 0.5)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdTLSNsQuantiles, 0.5)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdTTFBNsQuantiles, 0.5)),
+		Cyan(fmtLatency(p.ReqStats.ElpsdDownloadNsQuantiles, 0.5)),
+		Cyan(fmt.Sprintf("%s (%s%%)",
+			FGroup(int64(p.ReqStats.SumConnReused)),
+			fmt.Sprintf("%.2f", math.Floor(float64(p.ReqStats.PctConnReused*100))/100))),
 	)
 
 	i++
@@ -866,7 +1199,18 @@ func (p *P0d) doLogLive() {
 		FGroup(int64(p.ReqStats.SumMatchingResponseCodes)),
 		fmt.Sprintf("%.2f", math.Floor(float64(p.ReqStats.PctMatchingResponseCodes*100))/100)))
 
-	fmt.Fprintf(lw[i], timefmt(matchingResponseCodesMsg), mrc)
+	if p.Config.Res.Assertions.active() {
+		fa := fmt.Sprintf("%s (%s%%)",
+			FGroup(int64(p.ReqStats.SumAssertionFailures)),
+			fmt.Sprintf("%.2f", math.Ceil(float64(p.ReqStats.PctAssertionFailures*100))/100))
+		if p.ReqStats.SumAssertionFailures > 0 {
+			fmt.Fprintf(lw[i], timefmt(matchingResponseCodesMsg+failedAssertionsMsg), mrc, Red(fa))
+		} else {
+			fmt.Fprintf(lw[i], timefmt(matchingResponseCodesMsg+failedAssertionsMsg), mrc, Cyan(fa))
+		}
+	} else {
+		fmt.Fprintf(lw[i], timefmt(matchingResponseCodesMsg), mrc)
+	}
 
 	i++
 	tte := fmt.Sprintf("%s (%s%%)",
@@ -884,7 +1228,65 @@ func (p *P0d) doLogLive() {
 	logLiveLock.Unlock()
 }
 
+func fmtLatency(q *Quantile, v float64) string {
+	qv := q.Quantile(v)
+	if math.IsNaN(qv) {
+		qv = 0
+	}
+	c := time.Duration(int64(qv))
+	if c.Milliseconds() == 0 {
+		return FGroup(c.Microseconds()) + "μs"
+	} else {
+		return FGroup(c.Milliseconds()) + "ms"
+	}
+}
+
+const reqSummaryMsg = "  - req [%s]: %s/%s (%s%%) matching HTTP response codes: %s (%s%%) transport errors: %s (%s%%) latency pct50: %s pct99: %s"
+
+const journeySummaryMsg = "  - journeys: %s success: %s (%s%%) latency pct50: %s pct99: %s"
+
 func (p *P0d) logSummary() {
+	if p.Config.isScenario() {
+		logv(Cyan(fmt.Sprintf(journeySummaryMsg,
+			FGroup(p.ReqStats.JourneyAtmpts),
+			FGroup(int64(p.ReqStats.SumJourneySuccess)),
+			fmt.Sprintf("%.2f", math.Floor(float64(p.ReqStats.PctJourneySuccess*100))/100),
+			fmtLatency(p.ReqStats.ElpsdJourneyLatencyNsQuantiles, 0.5),
+			fmtLatency(p.ReqStats.ElpsdJourneyLatencyNsQuantiles, 0.99))))
+		for k, v := range p.ReqStats.JourneyErrorTypes {
+			pctv := 100 * (float32(v) / float32(p.ReqStats.JourneyAtmpts))
+			logv(Red(fmt.Sprintf("  - journey error: [%s]: %s/%s (%s%%)", k,
+				FGroup(int64(v)),
+				FGroup(p.ReqStats.JourneyAtmpts),
+				fmt.Sprintf("%.2f", math.Ceil(float64(pctv*100))/100))))
+		}
+	}
+	for _, r := range p.Config.reqs() {
+		if !p.Config.hasReqTemplates() {
+			break
+		}
+		rs := p.ReqStats.Reqs[r.Name]
+		pctv := 100 * (float32(rs.ReqAtmpts) / float32(p.ReqStats.ReqAtmpts))
+		logv(Cyan(fmt.Sprintf(reqSummaryMsg, r.Name,
+			FGroup(rs.ReqAtmpts),
+			FGroup(p.ReqStats.ReqAtmpts),
+			fmt.Sprintf("%.2f", math.Floor(float64(pctv*100))/100),
+			FGroup(int64(rs.SumMatchingResponseCodes)),
+			fmt.Sprintf("%.2f", math.Floor(float64(rs.PctMatchingResponseCodes*100))/100),
+			FGroup(int64(rs.SumErrors)),
+			fmt.Sprintf("%.2f", math.Ceil(float64(rs.PctErrors*100))/100),
+			fmtLatency(rs.ElpsdAtmptLatencyNsQuantiles, 0.5),
+			fmtLatency(rs.ElpsdAtmptLatencyNsQuantiles, 0.99))))
+	}
+
+	for k, v := range p.ReqStats.AssertionFailTypes {
+		pctv := 100 * (float32(v) / float32(p.ReqStats.ReqAtmpts))
+		logv(Red(fmt.Sprintf("  - failed assertion: [%s]: %s/%s (%s%%)", k,
+			FGroup(int64(v)),
+			FGroup(int64(p.ReqStats.ReqAtmpts)),
+			fmt.Sprintf("%.2f", math.Ceil(float64(pctv*100))/100))))
+	}
+
 	for k, v := range p.ReqStats.ErrorTypes {
 		pctv := 100 * (float32(v) / float32(p.ReqStats.ReqAtmpts))
 		err := Red(fmt.Sprintf("  - error: [%s]: %s/%s (%s%%)", k,
@@ -895,40 +1297,6 @@ func (p *P0d) logSummary() {
 	}
 }
 
-func (p *P0d) initOutFile() {
-	var oe error
-	if len(p.Output) > 0 {
-		p.outFile, oe = os.Create(p.Output)
-		p.outFileCheckWrite(oe)
-		_, we := p.outFile.Write([]byte("["))
-		p.outFileCheckWrite(we)
-	}
-}
-
-func (p *P0d) outFileCheckWrite(e error) {
-	if e != nil {
-		fmt.Println(e)
-		msg := Red(fmt.Sprintf("unable to write to output file %s", p.Output))
-		logv(msg)
-		syscall.Kill(syscall.Getpid(), syscall.SIGINT)
-	}
-}
-
-func (p *P0d) outFileRequestAttempt(ra ReqAtmpt, prefix string, indent string, comma []byte) {
-	if len(p.Output) > 0 {
-		rand.Seed(time.Now().UnixNano())
-		//only sample a subset of requests
-		if rand.Float64() < p.Config.Exec.LogSampling {
-			j, je := json.MarshalIndent(ra, prefix, indent)
-			p.outFileCheckWrite(je)
-			_, we := p.outFile.Write(j)
-			p.outFileCheckWrite(we)
-			_, we = p.outFile.Write(comma)
-			p.outFileCheckWrite(we)
-		}
-	}
-}
-
 func (p *P0d) initOSStats(done chan struct{}) {
 	p.OS.PID = os.Getpid()
 	if !p.Config.Exec.SkipInetTest {
@@ -1003,6 +1371,24 @@ func (p *P0d) getOSINetSpeed(maxWaitSeconds int) {
 	}
 }
 
+func (p *P0d) awaitInetTest() string {
+	if p.Config.Exec.SkipInetTest || p.OS.InetTestAborted {
+		return N
+	}
+	select {
+	case <-p.interrupt:
+		p.OS.InetTestAborted = true
+		p.Interrupted = true
+	case <-p.stopping:
+		p.OS.InetTestAborted = true
+	case <-p.OS.inetTestError:
+	case <-p.OS.inetLatencyDone:
+		return fmt.Sprintf("detected inet ▼️ speed %.2fMBit/s, ▲ speed %.2fMBit/s, latency %s", p.OS.InetDlSpeedMBits,
+			p.OS.InetUlSpeedMBits, durafmt.Parse(p.OS.InetLatencyNs).LimitFirstN(1).String())
+	}
+	return "unable to detect inet speed"
+}
+
 func (p *P0d) doOSOpenConns() {
 	p.OS.updateLock.Lock()
 	oss := NewOSOpenConns(p.OS.PID)
@@ -1031,6 +1417,10 @@ func (p *P0d) getOSOpenConns() OSOpenConns {
 func (p *P0d) setTimerPhase(phase TimerPhase) {
 	if phase > p.Time.Phase {
 		p.Time.Phase = phase
+		select {
+		case p.phases <- phase:
+		default:
+		}
 	}
 }
 
@@ -1,6 +1,7 @@
 package p0d
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -145,6 +146,39 @@ func TestDoReqAtmpt(t *testing.T) {
 	if ra.ResErr != "" {
 		t.Error("should not have errored")
 	}
+	if ra.Intended != ra.Start || ra.ElpsdCorrectedNs != ra.ElpsdNs {
+		t.Error("without spacing intended start should be actual start")
+	}
+}
+
+func TestDoReqAtmptWithSpacing(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get.yml", "")
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//slower than spacing, so every request after the first is late.
+		time.Sleep(time.Millisecond * 50)
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p.Config.Req.Url = svr.URL
+	p.Config.Exec.SpacingMillis = 10
+
+	ras := make(chan ReqAtmpt, 65535)
+	done := make(chan struct{}, 1)
+	go p.doReqAtmpts(0, ras, done)
+
+	<-ras
+	<-ras
+	ra := <-ras
+	done <- struct{}{}
+
+	if !ra.Intended.Before(ra.Start) {
+		t.Error("third request should have been sent late")
+	}
+	if ra.ElpsdCorrectedNs <= ra.ElpsdNs {
+		t.Error("corrected latency should include time spent waiting")
+	}
 }
 
 func TestRace(t *testing.T) {
@@ -158,7 +192,7 @@ func TestRace(t *testing.T) {
 	//we hack the config's URL to point at our mock server so we can execute the test
 	p.Config.Req.Url = svr.URL
 	p.Config.Exec.DurationSeconds = 1
-	p.Race()
+	p.Race(context.Background())
 
 }
 
@@ -175,12 +209,42 @@ func TestRaceWithOutput(t *testing.T) {
 
 	//test this only shortly
 	p.Config.Exec.DurationSeconds = 1
-	p.Race()
+	p.Race(context.Background())
+
+	if len(p.ReqStats.Series) == 0 || p.ReqStats.Series[0].ReqAtmpts == 0 {
+		t.Error("output should have a time series")
+	}
 
 	//for good measure
 	os.Remove(p.Output)
 }
 
+func TestRaceWithReqs(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_mix.yml", "")
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	//we hack the config's URLs to point at our mock server so we can execute the test
+	p.Config.Req.Url = svr.URL
+	for i := range p.Config.Reqs {
+		p.Config.Reqs[i].Url = svr.URL
+	}
+	p.Config.Exec.Concurrency = 4
+	p.Config.Exec.DurationSeconds = 1
+	p.Race(context.Background())
+
+	sum := int64(0)
+	for _, r := range p.Config.Reqs {
+		sum += p.ReqStats.Reqs[r.Name].ReqAtmpts
+	}
+	if sum == 0 || sum != p.ReqStats.ReqAtmpts {
+		t.Errorf("per request stats should add up to aggregate, got %v want %v", sum, p.ReqStats.ReqAtmpts)
+	}
+}
+
 func TestTimerPhase(t *testing.T) {
 	p := P0d{Time: Time{Phase: Bootstrap}}
 
@@ -334,3 +398,26 @@ func TestInitLogWithOSInetTestAborted(t *testing.T) {
 //	p := NewP0dWithValues(7, 6, "http://localhost/", "1.1", "", true)
 //	p.getOSINetSpeed(20)
 //}
+
+func TestDoReqAtmptPhases(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 20)
+		w.Write([]byte("123456789"))
+	}))
+	defer svr.Close()
+
+	p := NewP0dWithValues(1, 3, svr.URL, "1.1", "", true)
+
+	ra := p.doReqAtmptFor(0, time.Time{}, &p.Config.Req, nil, nil)
+	if ra.ConnReused || ra.ConnectNs == 0 {
+		t.Error("first attempt should connect")
+	}
+	if ra.TTFBNs < time.Millisecond*20 || ra.TTFBNs > ra.ElpsdNs {
+		t.Errorf("ttfb should include server time, got %v", ra.TTFBNs)
+	}
+
+	ra = p.doReqAtmptFor(0, time.Time{}, &p.Config.Req, nil, nil)
+	if !ra.ConnReused || ra.ConnectNs != 0 {
+		t.Error("second attempt should reuse its conn")
+	}
+}
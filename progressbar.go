@@ -17,15 +17,17 @@ type ProgressBar struct {
 }
 
 type ChunkProps struct {
-	index     int
-	isRamp    bool
-	hasErrors bool
+	index        int
+	isRamp       bool
+	hasErrors    bool
+	isStageStart bool
 }
 
 const EMPTY = " "
 const RAMP = "-"
 const FULL = "="
 const CURRENT = ">"
+const STAGE = "|"
 const OPEN = "["
 const CLOSE = "]"
 const rt = "runtime: "
@@ -50,6 +52,18 @@ func (p *ProgressBar) markError(chunkTime time.Time, pod *P0d) {
 	p.chunkProps[i].hasErrors = p.chunkProps[i].hasErrors || true
 }
 
+func (p *ProgressBar) markStages(pod *P0d) {
+	//the first stage starts with the bar, we only show boundaries between stages.
+	offset := 0
+	for i, s := range pod.Config.Exec.Stages {
+		if i > 0 {
+			ci := p.chunkPropIndexFor(pod.Time.Start.Add(time.Duration(offset)*time.Second), pod)
+			p.chunkProps[ci].isStageStart = true
+		}
+		offset += s.DurationSeconds
+	}
+}
+
 func (p *ProgressBar) chunkPropIndexFor(chunkTime time.Time, pod *P0d) int {
 	chunkSizeSeconds := float64(pod.Config.Exec.DurationSeconds) / float64(p.size)
 	elapsed := chunkTime.Sub(pod.Time.Start).Seconds()
@@ -77,7 +91,13 @@ func (p *ProgressBar) render(now time.Time, pod *P0d) string {
 		f := strings.Builder{}
 		for i := 0; i <= fsi; i++ {
 			if i < fsi {
-				if p.chunkProps[i].isRamp == true {
+				if p.chunkProps[i].isStageStart {
+					if p.chunkProps[i].hasErrors {
+						f.WriteString(Red(STAGE).String())
+					} else {
+						f.WriteString(Yellow(STAGE).String())
+					}
+				} else if p.chunkProps[i].isRamp == true {
 					if p.chunkProps[i].hasErrors {
 						f.WriteString(Red(RAMP).String())
 					} else {
@@ -101,7 +121,11 @@ func (p *ProgressBar) render(now time.Time, pod *P0d) string {
 		b.WriteString(f.String())
 
 		for j := fsi; j < p.size-1; j++ {
-			b.WriteString(EMPTY)
+			if p.chunkProps[j+1].isStageStart {
+				b.WriteString(Yellow(STAGE).String())
+			} else {
+				b.WriteString(EMPTY)
+			}
 		}
 		b.WriteString(Yellow(CLOSE).String())
 		//remaining whole seconds
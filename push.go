@@ -0,0 +1,266 @@
+package p0d
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PushInflux   = "influx"
+	PushGraphite = "graphite"
+)
+
+const pushInterval = time.Second
+const pushTimeout = time.Second * 2
+const pushBuffer = 60
+
+type Push struct {
+	Format  string
+	Url     string
+	Prefix  string
+	Headers map[string]string
+
+	u *url.URL
+}
+
+type pushSnapshot struct {
+	time   time.Time
+	phase  string
+	fields []snapshotField
+}
+
+type snapshotField struct {
+	name  string
+	value float64
+	isInt bool
+}
+
+type pusher struct {
+	cfg       Push
+	tags      [][2]string
+	snapshots chan pushSnapshot
+	done      chan struct{}
+	client    *http.Client
+	conn      net.Conn
+	errors    int64
+}
+
+func (cfg *Config) validatePush() {
+	ps := &cfg.Exec.Push
+	if ps.Url == N {
+		return
+	}
+	u, e := url.Parse(ps.Url)
+	if e != nil {
+		cfg.panic(fmt.Sprintf("unable to parse push url: %s", e.Error()))
+	}
+	ps.u = u
+
+	ps.Format = strings.ToLower(ps.Format)
+	if ps.Format == N {
+		ps.Format = PushInflux
+	}
+	switch ps.Format {
+	case PushInflux:
+		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "udp" {
+			cfg.panic(fmt.Sprintf("influx push url must be http, https or udp, yours: %s", ps.Url))
+		}
+	case PushGraphite:
+		if u.Scheme != "tcp" {
+			cfg.panic(fmt.Sprintf("graphite push url must be tcp, yours: %s", ps.Url))
+		}
+	default:
+		cfg.panic(fmt.Sprintf("push format must be one of %s, %s, yours: %s", PushInflux, PushGraphite, ps.Format))
+	}
+	if ps.Prefix == N {
+		ps.Prefix = "p0d"
+	}
+}
+
+func (p *P0d) initPusher() {
+	if p.Config.Exec.Push.Url == N {
+		return
+	}
+	p.pusher = &pusher{
+		cfg:       p.Config.Exec.Push,
+		tags:      [][2]string{{"run", p.ID}, {"url", p.Config.Req.Url}},
+		snapshots: make(chan pushSnapshot, pushBuffer),
+		done:      make(chan struct{}),
+		client:    &http.Client{Timeout: pushTimeout},
+	}
+	go p.pusher.run()
+	p.logf("pushing metrics in %s format to %s", Yellow(p.Config.Exec.Push.Format), Yellow(p.Config.Exec.Push.Url))
+}
+
+func (p *P0d) pushSnapshot(now time.Time) {
+	if p.pusher == nil {
+		return
+	}
+	snap := pushSnapshot{
+		time:   now,
+		phase:  timerPhaseNames[p.Time.Phase],
+		fields: p.snapshotFields(),
+	}
+
+	//never block the main loop on a slow or dead sink, drop the snapshot instead.
+	select {
+	case p.pusher.snapshots <- snap:
+	default:
+		atomic.AddInt64(&p.pusher.errors, 1)
+	}
+}
+
+func (p *P0d) snapshotFields() []snapshotField {
+	s := p.ReqStats
+	lat := func(q *Quantile, v float64) float64 {
+		qv := q.Quantile(v)
+		if math.IsNaN(qv) {
+			return 0
+		}
+		return math.Ceil(qv)
+	}
+	return []snapshotField{
+		{"reqs", float64(s.ReqAtmpts), true},
+		{"rps", float64(atomic.LoadInt64(&s.CurReqAtmptsPSec)), true},
+		{"meanRps", float64(s.MeanReqAtmptsPSec), true},
+		{"maxRps", float64(s.MaxReqAtmptsPSec), true},
+		{"dropped", float64(atomic.LoadInt64(&s.SumDroppedReqAtmpts)), true},
+		{"latencyP10Ns", lat(s.ElpsdAtmptLatencyNsQuantiles, 0.1), true},
+		{"latencyP50Ns", lat(s.ElpsdAtmptLatencyNsQuantiles, 0.5), true},
+		{"latencyP90Ns", lat(s.ElpsdAtmptLatencyNsQuantiles, 0.9), true},
+		{"latencyP99Ns", lat(s.ElpsdAtmptLatencyNsQuantiles, 0.99), true},
+		{"correctedP50Ns", lat(s.ElpsdAtmptCorrectedLatencyNsQuantiles, 0.5), true},
+		{"correctedP90Ns", lat(s.ElpsdAtmptCorrectedLatencyNsQuantiles, 0.9), true},
+		{"correctedP99Ns", lat(s.ElpsdAtmptCorrectedLatencyNsQuantiles, 0.99), true},
+		{"bytesReadPSec", float64(atomic.LoadInt64(&s.CurBytesReadPSec)), true},
+		{"bytesRead", float64(s.SumBytesRead), true},
+		{"bytesWrittenPSec", float64(atomic.LoadInt64(&s.CurBytesWrittenPSec)), true},
+		{"bytesWritten", float64(s.SumBytesWritten), true},
+		{"matchingCodes", float64(s.SumMatchingResponseCodes), true},
+		{"matchingCodesPct", float64(s.PctMatchingResponseCodes), false},
+		{"errors", float64(s.SumErrors), true},
+		{"errorsPct", float64(s.PctErrors), false},
+		{"openConns", float64(p.getOSOpenConns().OpenConns), true},
+		{"concurrency", float64(p.Config.Exec.Concurrency), true},
+	}
+}
+
+func (p *P0d) stopPusher() {
+	if p.pusher == nil {
+		return
+	}
+	close(p.pusher.snapshots)
+	select {
+	case <-p.pusher.done:
+	case <-time.After(pushTimeout):
+	}
+}
+
+func (ps *pusher) run() {
+	defer close(ps.done)
+	for snap := range ps.snapshots {
+		var b []byte
+		if ps.cfg.Format == PushGraphite {
+			b = ps.graphite(snap)
+		} else {
+			b = ps.influx(snap)
+		}
+		if e := ps.send(b); e != nil {
+			atomic.AddInt64(&ps.errors, 1)
+		}
+	}
+	if ps.conn != nil {
+		ps.conn.Close()
+	}
+}
+
+func (ps *pusher) send(b []byte) error {
+	switch ps.cfg.u.Scheme {
+	case "http", "https":
+		req, _ := http.NewRequest("POST", ps.cfg.Url, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		for k, v := range ps.cfg.Headers {
+			req.Header.Set(k, v)
+		}
+		res, e := ps.client.Do(req)
+		if e != nil {
+			return e
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			return fmt.Errorf("push sink returned HTTP %d", res.StatusCode)
+		}
+		return nil
+	default:
+		//udp is connectionless, tcp we keep open and redial after errors.
+		if ps.conn == nil {
+			c, e := net.DialTimeout(ps.cfg.u.Scheme, ps.cfg.u.Host, pushTimeout)
+			if e != nil {
+				return e
+			}
+			ps.conn = c
+		}
+		ps.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+		if _, e := ps.conn.Write(b); e != nil {
+			ps.conn.Close()
+			ps.conn = nil
+			return e
+		}
+		return nil
+	}
+}
+
+func (ps *pusher) influx(snap pushSnapshot) []byte {
+	b := bytes.Buffer{}
+	b.WriteString(influxEscape(ps.cfg.Prefix, ", "))
+	for _, t := range append(ps.tags, [2]string{"phase", snap.phase}) {
+		fmt.Fprintf(&b, ",%s=%s", influxEscape(t[0], ",= "), influxEscape(t[1], ",= "))
+	}
+	for i, f := range snap.fields {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		if f.isInt {
+			fmt.Fprintf(&b, "%s%s=%di", sep, f.name, int64(f.value))
+		} else {
+			fmt.Fprintf(&b, "%s%s=%s", sep, f.name, strconv.FormatFloat(f.value, 'f', -1, 64))
+		}
+	}
+	fmt.Fprintf(&b, " %d\n", snap.time.UnixNano())
+	return b.Bytes()
+}
+
+func influxEscape(s string, chars string) string {
+	for _, c := range chars {
+		s = strings.ReplaceAll(s, string(c), `\`+string(c))
+	}
+	return s
+}
+
+func (ps *pusher) graphite(snap pushSnapshot) []byte {
+	//graphite tags go on every metric name, i.e. p0d.reqs;run=x;phase=main 12 1700000000
+	tags := bytes.Buffer{}
+	for _, t := range append(ps.tags, [2]string{"phase", snap.phase}) {
+		fmt.Fprintf(&tags, ";%s=%s", t[0], graphiteEscape(t[1]))
+	}
+	b := bytes.Buffer{}
+	for _, f := range snap.fields {
+		fmt.Fprintf(&b, "%s.%s%s %s %d\n", ps.cfg.Prefix, f.name, tags.String(),
+			strconv.FormatFloat(f.value, 'f', -1, 64), snap.time.Unix())
+	}
+	return b.Bytes()
+}
+
+func graphiteEscape(s string) string {
+	return strings.NewReplacer(";", "_", "~", "_", " ", "_").Replace(s)
+}
@@ -0,0 +1,120 @@
+package p0d
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pushP0d(format string, u string) *P0d {
+	cfg := Config{
+		Req:  Req{Url: "http://localhost:8080/a b"},
+		Res:  Res{Code: 200},
+		Exec: Exec{Concurrency: 4, Push: Push{Format: format, Url: u}},
+	}
+	cfg.validatePush()
+	p := &P0d{ID: "run1", Config: cfg, ReqStats: NewReqStats(cfg), Time: Time{Phase: Main}}
+	now := time.Now()
+	p.ReqStats.setStart(now.Add(-time.Second))
+	p.ReqStats.update(ReqAtmpt{ResCode: 200, ElpsdNs: time.Millisecond}, now, cfg)
+	p.initPusher()
+	return p
+}
+
+func TestValidatePush(t *testing.T) {
+	cfg := Config{Exec: Exec{Push: Push{Url: "udp://localhost:8089"}}}
+	cfg.validatePush()
+	if cfg.Exec.Push.Format != PushInflux || cfg.Exec.Push.Prefix != "p0d" {
+		t.Error("push defaults incorrect")
+	}
+}
+
+func TestPushInfluxHttp(t *testing.T) {
+	lines := make(chan string, 10)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		lines <- string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	p := pushP0d(PushInflux, svr.URL+"/api/v2/write?bucket=p0d")
+	now := time.Unix(1700000000, 0)
+	p.pushSnapshot(now)
+	p.stopPusher()
+
+	l := <-lines
+	if !strings.HasPrefix(l, `p0d,run=run1,url=http://localhost:8080/a\ b,phase=main reqs=1i,`) {
+		t.Errorf("influx line incorrect, got %v", l)
+	}
+	if !strings.Contains(l, ",matchingCodesPct=100,") || !strings.HasSuffix(l, " 1700000000000000000\n") {
+		t.Errorf("influx fields incorrect, got %v", l)
+	}
+	if p.pusher.errors != 0 {
+		t.Error("should push without errors")
+	}
+}
+
+func TestPushInfluxUdp(t *testing.T) {
+	pc, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	defer pc.Close()
+
+	p := pushP0d(PushInflux, "udp://"+pc.LocalAddr().String())
+	p.pushSnapshot(time.Now())
+	p.stopPusher()
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, e := pc.ReadFrom(buf)
+	if e != nil || !strings.HasPrefix(string(buf[:n]), "p0d,run=run1") {
+		t.Errorf("should receive influx line over udp, got %v %v", string(buf[:n]), e)
+	}
+}
+
+func TestPushGraphiteTcp(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	got := make(chan string, 1)
+	go func() {
+		c, e := l.Accept()
+		if e != nil {
+			return
+		}
+		line, _ := bufio.NewReader(c).ReadString('\n')
+		got <- line
+		c.Close()
+	}()
+
+	p := pushP0d(PushGraphite, "tcp://"+l.Addr().String())
+	p.pushSnapshot(time.Unix(1700000000, 0))
+	p.stopPusher()
+
+	line := <-got
+	if line != "p0d.reqs;run=run1;url=http://localhost:8080/a_b;phase=main 1 1700000000\n" {
+		t.Errorf("graphite line incorrect, got %v", line)
+	}
+}
+
+func TestPushDeadSinkDoesNotBlock(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	p := pushP0d(PushGraphite, "tcp://"+addr)
+	start := time.Now()
+	for i := 0; i < pushBuffer*2; i++ {
+		p.pushSnapshot(time.Now())
+	}
+	if time.Since(start) > time.Millisecond*100 {
+		t.Error("pushing to a dead sink should not block")
+	}
+	p.stopPusher()
+	if p.pusher.errors == 0 {
+		t.Error("should count push errors")
+	}
+}
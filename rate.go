@@ -0,0 +1,121 @@
+package p0d
+
+import (
+	"github.com/hako/durafmt"
+	. "github.com/logrusorgru/aurora"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const maxScheduleStep = time.Millisecond * 10
+
+// workers a rate needs to keep up with responses of up to this long, see Little's law.
+const rateLatencyBudget = time.Millisecond * 250
+
+func (cfg Config) isRateMode() bool {
+	return cfg.Exec.Rate > 0
+}
+
+func (cfg Config) ratePoolSize() int {
+	n := int(math.Ceil(float64(cfg.Exec.Rate) * rateLatencyBudget.Seconds()))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (p *P0d) warnRatePool() {
+	//rates above what the pool can serve end up as dropped attempts, say so before they do.
+	if !p.Config.isRateMode() || p.Config.Exec.Concurrency >= p.Config.ratePoolSize() {
+		return
+	}
+	p.logf("%s concurrency %s keeps up with a rate of %s%s only while responses take under %s, raise exec.concurrency or expect dropped requests",
+		Yellow("warning:"),
+		Yellow(FGroup(int64(p.Config.Exec.Concurrency))),
+		Yellow(FGroup(int64(p.Config.Exec.Rate))),
+		Yellow(perSecondMsg),
+		Yellow(durafmt.Parse(time.Duration(float64(time.Second)*float64(p.Config.Exec.Concurrency)/float64(p.Config.Exec.Rate))).LimitFirstN(2).String()))
+}
+
+func (p *P0d) scheduleRate(now time.Time) float64 {
+	if p.Config.hasStages() {
+		_, r := p.stageAt(now)
+		return r
+	}
+
+	//follow the same linear ramp up and down the closed loop uses when staggering its workers.
+	rate := float64(p.Config.Exec.Rate)
+	elapsed := now.Sub(p.Time.Start).Seconds()
+	ramp := float64(p.Config.Exec.RampSeconds)
+	total := float64(p.Config.Exec.DurationSeconds)
+
+	if elapsed < 0 || elapsed > total {
+		return 0
+	}
+	if ramp > 0 {
+		if elapsed < ramp {
+			return rate * elapsed / ramp
+		}
+		if elapsed > total-ramp {
+			return rate * (total - elapsed) / ramp
+		}
+	}
+	return rate
+}
+
+func (p *P0d) initRateScheduler(done <-chan struct{}, sched chan<- time.Time) {
+	//issue intended start times on a fixed timetable, independent of how long responses take.
+	go func() {
+		next := time.Now()
+		credit := 0.0
+	Schedule:
+		for {
+			select {
+			case <-done:
+				break Schedule
+			default:
+			}
+
+			//never sleep longer than max step between evaluating the rate, so ramps stay smooth.
+			r := p.scheduleRate(next)
+			step := maxScheduleStep
+			if r > 0 {
+				if i := time.Duration(float64(time.Second) / r); i < step {
+					step = i
+				}
+			}
+			credit += r * step.Seconds()
+			next = next.Add(step)
+
+			if d := time.Until(next); d > 0 {
+				time.Sleep(d)
+			}
+
+			for credit >= 1 {
+				credit--
+				//if no worker is waiting when a request is due, it's dropped/late, not queued.
+				select {
+				case sched <- next:
+				default:
+					atomic.AddInt64(&p.ReqStats.SumDroppedReqAtmpts, 1)
+				}
+			}
+		}
+	}()
+}
+
+func (p *P0d) doRateReqAtmpts(i int, ras chan<- ReqAtmpt, done <-chan struct{}, sched <-chan time.Time) {
+ReqAtmpt:
+	for {
+		select {
+		case <-done:
+			break ReqAtmpt
+		case intended := <-sched:
+			if !p.doIteration(i, intended, ras) {
+				<-done
+				break ReqAtmpt
+			}
+		}
+	}
+}
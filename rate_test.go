@@ -0,0 +1,127 @@
+package p0d
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduleRate(t *testing.T) {
+	p := P0d{
+		Config: Config{
+			Exec: Exec{
+				DurationSeconds: 10,
+				RampSeconds:     2,
+				Rate:            100,
+			},
+		},
+	}
+	p.Time.Start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type rateTest struct {
+		deltaSecond float64
+		wantRate    float64
+	}
+
+	tests := []rateTest{
+		{deltaSecond: -1, wantRate: 0},
+		{deltaSecond: 0, wantRate: 0},
+		{deltaSecond: 1, wantRate: 50},
+		{deltaSecond: 2, wantRate: 100},
+		{deltaSecond: 5, wantRate: 100},
+		{deltaSecond: 9, wantRate: 50},
+		{deltaSecond: 10, wantRate: 0},
+		{deltaSecond: 11, wantRate: 0},
+	}
+
+	for _, tc := range tests {
+		now := p.Time.Start.Add(time.Duration(tc.deltaSecond * float64(time.Second)))
+		got := p.scheduleRate(now)
+		if got != tc.wantRate {
+			t.Errorf("bad rate for deltaSeconds %v, want %v got %v", tc.deltaSecond, tc.wantRate, got)
+		}
+	}
+}
+
+func TestRateSchedulerDropsWithoutWorkers(t *testing.T) {
+	p := P0d{
+		Config: Config{
+			Exec: Exec{
+				DurationSeconds: 10,
+				Rate:            1000,
+			},
+		},
+		ReqStats: &ReqStats{},
+	}
+	p.Time.Start = time.Now()
+
+	done := make(chan struct{}, 1)
+	sched := make(chan time.Time)
+	p.initRateScheduler(done, sched)
+	time.Sleep(time.Millisecond * 200)
+	done <- struct{}{}
+
+	//nobody is receiving on sched, so every scheduled request must be dropped
+	d := atomic.LoadInt64(&p.ReqStats.SumDroppedReqAtmpts)
+	if d < 100 || d > 300 {
+		t.Errorf("dropped requests incorrect, want ~200, got %v", d)
+	}
+}
+
+func TestRaceRateMode(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get_rate.yml", "")
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	//we hack the config's URL to point at our mock server so we can execute the test
+	p.Config.Req.Url = svr.URL
+	p.Config.Exec.DurationSeconds = 2
+	p.Config.Exec.RampSeconds = 1
+	p.Race(context.Background())
+
+	if p.ReqStats.ReqAtmpts == 0 {
+		t.Error("rate mode should have sent requests")
+	}
+	//ramp up and down over a second each at 100/s offers roughly 100 requests.
+	if p.ReqStats.ReqAtmpts > 150 {
+		t.Errorf("rate mode sent too many requests, got %v", p.ReqStats.ReqAtmpts)
+	}
+}
+
+func TestRatePoolSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		exec        Exec
+		concurrency int
+	}{
+		{"closed loop", Exec{}, 1},
+		{"small rate", Exec{Rate: 2}, 1},
+		{"large rate", Exec{Rate: 1000}, 250},
+		{"rate stages", Exec{Stages: []Stage{{DurationSeconds: 2, Rate: 100}, {DurationSeconds: 2, Rate: 400}}}, 100},
+		{"explicit", Exec{Rate: 1000, Concurrency: 8}, 8},
+	}
+	for _, tt := range tests {
+		cfg := Config{Req: Req{Url: "http://localhost/"}, Exec: tt.exec}
+		cfg.validate()
+		if cfg.Exec.Concurrency != tt.concurrency {
+			t.Errorf("%s: want concurrency %d, got %d", tt.name, tt.concurrency, cfg.Exec.Concurrency)
+		}
+	}
+}
+
+func TestWarnRatePool(t *testing.T) {
+	p := NewP0d(Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{Rate: 1000, Concurrency: 8}}, 0, "", 10, nil)
+	c := &countingReporter{}
+	p.SetReporters(c)
+	p.warnRatePool()
+	if len(c.logs) != 1 {
+		t.Errorf("want a warning for a pool that can't keep up, got %v", c.logs)
+	}
+}
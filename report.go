@@ -0,0 +1,468 @@
+package p0d
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const chartWidth = 720
+const chartHeight = 220
+const chartPad = 48
+
+var chartColors = []string{"#0b7fab", "#e0a400", "#c0392b", "#7d3c98"}
+
+var latencyPcts = []string{"min", "p10", "p25", "p50", "p75", "p90", "p99", "p99.9", "max"}
+
+type reportStats struct {
+	ElpsdNs                               time.Duration
+	ReqAtmpts                             int64
+	MeanReqAtmptsPSec                     int64
+	MaxReqAtmptsPSec                      int64
+	SumDroppedReqAtmpts                   int64
+	PctDroppedReqAtmpts                   float32
+	SumBytesRead                          int64
+	MeanBytesReadPSec                     int64
+	SumBytesWritten                       int64
+	MeanBytesWrittenPSec                  int64
+	ElpsdAtmptLatencyNsQuantiles          reportQuantiles
+	ElpsdAtmptCorrectedLatencyNsQuantiles reportQuantiles
+	SumMatchingResponseCodes              int
+	PctMatchingResponseCodes              float32
+	SumAssertionFailures                  int
+	PctAssertionFailures                  float32
+	AssertionFailTypes                    map[string]int
+	Sample                                Sample
+	SumErrors                             int
+	PctErrors                             float32
+	ErrorTypes                            map[string]int
+	Series                                []*Bucket
+}
+
+type reportQuantiles map[string]int64
+
+func (rq *reportQuantiles) UnmarshalJSON(b []byte) error {
+	//hdr quantiles also have the encoded histogram, we only want the numbers.
+	var m map[string]json.RawMessage
+	if e := json.Unmarshal(b, &m); e != nil {
+		return e
+	}
+	*rq = make(reportQuantiles)
+	for k, v := range m {
+		var n int64
+		if json.Unmarshal(v, &n) == nil {
+			(*rq)[k] = n
+		}
+	}
+	return nil
+}
+
+type reportRun struct {
+	ID     string
+	Time   Time
+	Config json.RawMessage
+	OS     struct {
+		OpenConns    []OSOpenConns
+		MaxOpenConns int
+	}
+	ReqStats    reportStats
+	Interrupted bool
+	AbortReason string
+	Verdict     *Verdict
+}
+
+type reportRow struct {
+	Name  string
+	Value string
+}
+
+type reportCount struct {
+	Name  string
+	Count int
+	Pct   string
+}
+
+type reportPage struct {
+	Run       *reportRun
+	Version   string
+	Generated string
+	Config    string
+	Summary   []reportRow
+	Errors    []reportCount
+	Asserts   []reportCount
+	Latency   template.HTML
+	Over      template.HTML
+	Rps       template.HTML
+	Conns     template.HTML
+}
+
+func parseReport(j []byte) (*reportRun, error) {
+	//-O files are a json array of sampled attempts with the run itself last, or json lines with a summary record.
+	j = bytes.TrimSpace(j)
+	if isJsonl(j) {
+		var e error
+		if j, e = parseJsonl(j); e != nil {
+			return nil, e
+		}
+	} else if len(j) > 0 && j[0] == '[' {
+		var all []json.RawMessage
+		if e := json.Unmarshal(j, &all); e != nil {
+			return nil, e
+		}
+		if len(all) == 0 {
+			return nil, fmt.Errorf("no test run found")
+		}
+		j = all[len(all)-1]
+	}
+	r := &reportRun{}
+	if e := json.Unmarshal(j, r); e != nil {
+		return nil, e
+	}
+	if r.ID == N {
+		return nil, fmt.Errorf("no test run found")
+	}
+	return r, nil
+}
+
+func loadRun(file string) (*reportRun, error) {
+	j, e := os.ReadFile(file)
+	if e != nil {
+		return nil, e
+	}
+	r, e := parseReport(j)
+	if e != nil {
+		return nil, fmt.Errorf("unable to read test run from %s: %s", file, e.Error())
+	}
+	return r, nil
+}
+
+func WriteReport(in string, out string) error {
+	r, e := loadRun(in)
+	if e != nil {
+		return e
+	}
+	return r.write(out)
+}
+
+func (p *P0d) writeReport() {
+	if p.Report == N {
+		return
+	}
+	p.logf("writing report '%s'", Yellow(p.Report))
+	//the report reads the same json we save with -O, so both ways of getting one look alike.
+	j, e := json.Marshal(p)
+	if e == nil {
+		var r *reportRun
+		if r, e = parseReport(j); e == nil {
+			e = r.write(p.Report)
+		}
+	}
+	if e != nil {
+		p.logf("%v", Red(fmt.Sprintf("unable to write report %s: %s", p.Report, e.Error())))
+		if p.outErr == nil {
+			p.outErr = e
+		}
+	}
+}
+
+func (r *reportRun) write(out string) error {
+	b := bytes.Buffer{}
+	if e := reportTemplate.Execute(&b, r.page()); e != nil {
+		return e
+	}
+	return os.WriteFile(out, b.Bytes(), 0644)
+}
+
+func (r *reportRun) page() reportPage {
+	s := r.ReqStats
+	cfg := bytes.Buffer{}
+	if json.Indent(&cfg, r.Config, "", "  ") != nil {
+		cfg.Write(r.Config)
+	}
+	pg := reportPage{
+		Run:       r,
+		Version:   Version,
+		Generated: time.Now().Format(time.RFC1123),
+		Config:    cfg.String(),
+		Errors:    reportCounts(s.ErrorTypes, s.ReqAtmpts),
+		Asserts:   reportCounts(s.AssertionFailTypes, s.ReqAtmpts),
+	}
+	pct := func(v float32) string {
+		return fmt.Sprintf("%.2f%%", v)
+	}
+	pg.Summary = []reportRow{
+		{"runtime", r.Time.Stop.Sub(r.Time.Start).Round(time.Millisecond).String()},
+		{"HTTP requests", FGroup(s.ReqAtmpts)},
+		{"mean throughput", FGroup(s.MeanReqAtmptsPSec) + "/s"},
+		{"max throughput", FGroup(s.MaxReqAtmptsPSec) + "/s"},
+		{"latency pct50", fmtNs(s.ElpsdAtmptLatencyNsQuantiles["p50"])},
+		{"latency pct90", fmtNs(s.ElpsdAtmptLatencyNsQuantiles["p90"])},
+		{"latency pct99", fmtNs(s.ElpsdAtmptLatencyNsQuantiles["p99"])},
+		{"corrected latency pct99", fmtNs(s.ElpsdAtmptCorrectedLatencyNsQuantiles["p99"])},
+		{"bytes read", ByteCountIEC(s.SumBytesRead)},
+		{"bytes written", ByteCountIEC(s.SumBytesWritten)},
+		{"matching HTTP response codes", fmt.Sprintf("%s (%s)", FGroup(int64(s.SumMatchingResponseCodes)), pct(s.PctMatchingResponseCodes))},
+		{"transport errors", fmt.Sprintf("%s (%s)", FGroup(int64(s.SumErrors)), pct(s.PctErrors))},
+		{"failed assertions", fmt.Sprintf("%s (%s)", FGroup(int64(s.SumAssertionFailures)), pct(s.PctAssertionFailures))},
+		{"dropped requests", fmt.Sprintf("%s (%s)", FGroup(s.SumDroppedReqAtmpts), pct(s.PctDroppedReqAtmpts))},
+		{"max concurrent TCP conns", FGroup(int64(r.OS.MaxOpenConns))},
+	}
+
+	//latency percentiles as bars, uncorrected and corrected side by side
+	lat := make([]chartLine, 2)
+	lat[0] = chartLine{Name: "latency"}
+	lat[1] = chartLine{Name: "corrected"}
+	for _, k := range latencyPcts {
+		lat[0].Values = append(lat[0].Values, float64(s.ElpsdAtmptLatencyNsQuantiles[k]))
+		lat[1].Values = append(lat[1].Values, float64(s.ElpsdAtmptCorrectedLatencyNsQuantiles[k]))
+	}
+	pg.Latency = barChart(latencyPcts, lat, fmtNsFloat)
+
+	//the time series has one point per bucket, at the bucket's end
+	if len(s.Series) > 0 {
+		xs := make([]float64, len(s.Series))
+		rps := chartLine{Name: "requests/s"}
+		errs := chartLine{Name: "errors/s"}
+		p50 := chartLine{Name: "pct50"}
+		p90 := chartLine{Name: "pct90"}
+		p99 := chartLine{Name: "pct99"}
+		for i, b := range s.Series {
+			xs[i] = b.Start.Add(b.ElpsdNs).Sub(r.Time.Start).Seconds()
+			rps.Values = append(rps.Values, b.ReqAtmptsPSec)
+			secs := b.ElpsdNs.Seconds()
+			if secs == 0 {
+				secs = 1
+			}
+			errs.Values = append(errs.Values, float64(b.SumErrors)/secs)
+			p50.Values = append(p50.Values, float64(b.ElpsdAtmptLatencyNsP50))
+			p90.Values = append(p90.Values, float64(b.ElpsdAtmptLatencyNsP90))
+			p99.Values = append(p99.Values, float64(b.ElpsdAtmptLatencyNsP99))
+		}
+		pg.Rps = lineChart(xs, []chartLine{rps, errs}, func(v float64) string { return FGroup(int64(v)) })
+		pg.Over = lineChart(xs, []chartLine{p50, p90, p99}, fmtNsFloat)
+	}
+
+	if len(r.OS.OpenConns) > 0 {
+		xs := make([]float64, len(r.OS.OpenConns))
+		oc := chartLine{Name: "open TCP conns"}
+		for i, c := range r.OS.OpenConns {
+			xs[i] = c.Time.Sub(r.Time.Start).Seconds()
+			oc.Values = append(oc.Values, float64(c.OpenConns))
+		}
+		pg.Conns = lineChart(xs, []chartLine{oc}, func(v float64) string { return FGroup(int64(v)) })
+	}
+	return pg
+}
+
+func reportCounts(m map[string]int, total int64) []reportCount {
+	rc := make([]reportCount, 0, len(m))
+	for k, v := range m {
+		c := reportCount{Name: k, Count: v}
+		if total > 0 {
+			c.Pct = fmt.Sprintf("%.2f%%", 100*float64(v)/float64(total))
+		}
+		rc = append(rc, c)
+	}
+	sort.Slice(rc, func(i, j int) bool {
+		if rc[i].Count == rc[j].Count {
+			return rc[i].Name < rc[j].Name
+		}
+		return rc[i].Count > rc[j].Count
+	})
+	return rc
+}
+
+func fmtNs(ns int64) string {
+	d := time.Duration(ns)
+	if d.Milliseconds() == 0 {
+		return FGroup(d.Microseconds()) + "μs"
+	}
+	return FGroup(d.Milliseconds()) + "ms"
+}
+
+func fmtNsFloat(v float64) string {
+	return fmtNs(int64(v))
+}
+
+type chartLine struct {
+	Name   string
+	Values []float64
+}
+
+func chartMax(lines []chartLine) float64 {
+	m := 0.0
+	for _, l := range lines {
+		for _, v := range l.Values {
+			if !math.IsNaN(v) && v > m {
+				m = v
+			}
+		}
+	}
+	//a flat chart still needs a scale
+	if m == 0 {
+		m = 1
+	}
+	return m
+}
+
+func chartFrame(b *strings.Builder, ymax float64, yfmt func(float64) string) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" class="chart">`, chartWidth, chartHeight+chartPad)
+	for i := 0; i <= 4; i++ {
+		y := chartPad/2 + chartHeight - chartHeight*i/4
+		fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" class="grid"/>`, chartPad*2, y, chartWidth, y)
+		fmt.Fprintf(b, `<text x="%d" y="%d" class="axis" text-anchor="end">%s</text>`, chartPad*2-6, y+4,
+			template.HTMLEscapeString(yfmt(ymax*float64(i)/4)))
+	}
+}
+
+func chartLegend(b *strings.Builder, lines []chartLine) {
+	for i, l := range lines {
+		x := chartPad*2 + i*140
+		fmt.Fprintf(b, `<rect x="%d" y="4" width="10" height="10" fill="%s"/>`, x, chartColors[i%len(chartColors)])
+		fmt.Fprintf(b, `<text x="%d" y="13" class="axis">%s</text>`, x+14, template.HTMLEscapeString(l.Name))
+	}
+}
+
+func lineChart(xs []float64, lines []chartLine, yfmt func(float64) string) template.HTML {
+	b := strings.Builder{}
+	ymax := chartMax(lines)
+	xmax := 0.0
+	for _, x := range xs {
+		xmax = math.Max(xmax, x)
+	}
+	if xmax == 0 {
+		xmax = 1
+	}
+	chartFrame(&b, ymax, yfmt)
+	w := float64(chartWidth - chartPad*2)
+	px := func(x float64) float64 { return float64(chartPad*2) + w*x/xmax }
+	py := func(y float64) float64 { return float64(chartPad/2+chartHeight) - float64(chartHeight)*y/ymax }
+	for i, l := range lines {
+		pts := make([]string, 0, len(l.Values))
+		for j, v := range l.Values {
+			if math.IsNaN(v) {
+				continue
+			}
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", px(xs[j]), py(v)))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`,
+			strings.Join(pts, " "), chartColors[i%len(chartColors)])
+		//a short run may have a single bucket, which is no line at all
+		if len(pts) == 1 {
+			xy := strings.Split(pts[0], ",")
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="3" fill="%s"/>`, xy[0], xy[1], chartColors[i%len(chartColors)])
+		}
+	}
+	for i := 0; i <= 4; i++ {
+		x := xmax * float64(i) / 4
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="axis" text-anchor="middle">%.0fs</text>`,
+			px(x), chartHeight+chartPad-4, x)
+	}
+	chartLegend(&b, lines)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func barChart(labels []string, lines []chartLine, yfmt func(float64) string) template.HTML {
+	b := strings.Builder{}
+	ymax := chartMax(lines)
+	chartFrame(&b, ymax, yfmt)
+	slot := float64(chartWidth-chartPad*2) / float64(len(labels))
+	bw := slot * 0.8 / float64(len(lines))
+	for j, lb := range labels {
+		x0 := float64(chartPad*2) + slot*float64(j) + slot*0.1
+		for i, l := range lines {
+			h := float64(chartHeight) * l.Values[j] / ymax
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %s</title></rect>`,
+				x0+bw*float64(i), float64(chartPad/2+chartHeight)-h, bw, h, chartColors[i%len(chartColors)],
+				template.HTMLEscapeString(l.Name), template.HTMLEscapeString(yfmt(l.Values[j])))
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="axis" text-anchor="middle">%s</text>`,
+			x0+slot*0.4, chartHeight+chartPad-4, lb)
+	}
+	chartLegend(&b, lines)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>p0d report {{.Run.ID}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; max-width: 960px; margin: 2em auto; padding: 0 1em; }
+h1 { font-size: 1.6em; margin-bottom: 0; }
+h2 { font-size: 1.2em; border-bottom: 1px solid #ddd; padding-bottom: .2em; margin-top: 2em; }
+.meta { color: #777; font-size: .9em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: .3em .6em; border-bottom: 1px solid #eee; font-size: .95em; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.pass { color: #1e8449; font-weight: bold; }
+.fail { color: #c0392b; font-weight: bold; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; font-size: .85em; }
+.chart { width: 100%; height: auto; }
+.chart .grid { stroke: #eee; }
+.chart .axis { font-size: 11px; fill: #777; }
+</style>
+</head>
+<body>
+<h1>p0d report</h1>
+<p class="meta">run {{.Run.ID}} from {{.Run.Time.Start.Format "Mon, 02 Jan 2006 15:04:05 MST"}}, generated {{.Generated}} by p0d {{.Version}}</p>
+{{if .Run.Interrupted}}<p class="fail">the test was interrupted</p>{{end}}
+{{if .Run.AbortReason}}<p class="fail">aborted: {{.Run.AbortReason}}</p>{{end}}
+{{with .Run.Verdict}}
+<h2>Thresholds</h2>
+<p>{{if .Pass}}<span class="pass">passed</span>{{else}}<span class="fail">failed</span>{{end}}</p>
+<table>
+{{range .Thresholds}}<tr><td>{{.Threshold}}</td><td class="num">{{.Actual}}</td><td>{{if .Pass}}<span class="pass">passed</span>{{else}}<span class="fail">failed</span>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td></tr>
+{{end}}</table>
+<h2>Remote server</h2>
+<table>
+<tr><td>server</td><td>{{.Run.ReqStats.Sample.Server}}</td></tr>
+<tr><td>HTTP version</td><td>{{.Run.ReqStats.Sample.HTTPVersion}}</td></tr>
+<tr><td>TLS version</td><td>{{.Run.ReqStats.Sample.TLSVersion}}</td></tr>
+<tr><td>IP version</td><td>{{.Run.ReqStats.Sample.IPVersion}}</td></tr>
+<tr><td>remote address</td><td>{{.Run.ReqStats.Sample.RemoteAddr}}</td></tr>
+</table>
+<h2>Latency percentiles</h2>
+{{.Latency}}
+{{if .Rps}}
+<h2>Throughput over time</h2>
+{{.Rps}}
+<h2>Latency over time</h2>
+{{.Over}}
+{{end}}
+{{if .Conns}}
+<h2>Open TCP connections</h2>
+{{.Conns}}
+{{end}}
+<h2>Transport errors</h2>
+{{if .Errors}}<table>
+<tr><th>error</th><th>count</th><th>share of requests</th></tr>
+{{range .Errors}}<tr><td>{{.Name}}</td><td class="num">{{.Count}}</td><td class="num">{{.Pct}}</td></tr>
+{{end}}</table>{{else}}<p>none</p>{{end}}
+{{if .Asserts}}
+<h2>Failed assertions</h2>
+<table>
+<tr><th>assertion</th><th>count</th><th>share of requests</th></tr>
+{{range .Asserts}}<tr><td>{{.Name}}</td><td class="num">{{.Count}}</td><td class="num">{{.Pct}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Config</h2>
+<pre>{{.Config}}</pre>
+</body>
+</html>
+`))
@@ -0,0 +1,70 @@
+package p0d
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseReport(t *testing.T) {
+	_, e := parseReport([]byte(`[]`))
+	if e == nil {
+		t.Error("empty output should not parse")
+	}
+	_, e = parseReport([]byte(`[{"Start":"2025-01-01T00:00:00Z"}]`))
+	if e == nil {
+		t.Error("output without a test run should not parse")
+	}
+	r, e := parseReport([]byte(`[{"ResCode":200},{"ID":"abc","ReqStats":{"ReqAtmpts":12,"ErrorTypes":{"timeout":2}}}]`))
+	if e != nil || r.ID != "abc" || r.ReqStats.ReqAtmpts != 12 || r.ReqStats.ErrorTypes["timeout"] != 2 {
+		t.Errorf("should parse test run from last element, got %v %v", r, e)
+	}
+}
+
+func TestReportCounts(t *testing.T) {
+	rc := reportCounts(map[string]int{"b": 1, "a": 1, "c": 3}, 10)
+	if len(rc) != 3 || rc[0].Name != "c" || rc[1].Name != "a" || rc[0].Pct != "30.00%" {
+		t.Errorf("report counts should sort by count then name, got %v", rc)
+	}
+}
+
+func TestRaceWithReport(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get.yml", "testreport.json")
+	p.Report = "testreport.html"
+	defer os.Remove(p.Output)
+	defer os.Remove(p.Report)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p.Config.Req.Url = svr.URL
+	p.Config.Exec.DurationSeconds = 1
+	p.Race(context.Background())
+
+	b, e := os.ReadFile(p.Report)
+	if e != nil {
+		t.Fatalf("report should be written, got %v", e)
+	}
+	h := string(b)
+	for _, want := range []string{p.ID, "<svg", "Throughput over time", "Open TCP connections", svr.URL} {
+		if !strings.Contains(h, want) {
+			t.Errorf("report should contain %v", want)
+		}
+	}
+	if strings.Contains(h, "<script") || strings.Contains(h, "<link") {
+		t.Error("report should not load external assets")
+	}
+
+	//the subcommand reads the saved -O output
+	out := "testreport2.html"
+	defer os.Remove(out)
+	if e := WriteReport(p.Output, out); e != nil {
+		t.Errorf("should write report from output file, got %v", e)
+	}
+}
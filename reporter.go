@@ -0,0 +1,112 @@
+package p0d
+
+import (
+	"fmt"
+	"github.com/gosuri/uilive"
+)
+
+type Reporter interface {
+	OnStart(cfg Config)
+	OnLog(msg string)
+	OnPhase(phase TimerPhase)
+	OnAttempt(ra ReqAtmpt)
+	OnSnapshot(s *ReqStats)
+	OnFinish(p *P0d)
+}
+
+func (p *P0d) AddReporter(r Reporter) {
+	p.reporters = append(p.reporters, r)
+}
+
+func (p *P0d) SetReporters(r ...Reporter) {
+	p.reporters = r
+}
+
+func (p *P0d) onStart() {
+	for _, r := range p.reporters {
+		r.OnStart(p.Config)
+	}
+}
+
+func (p *P0d) logf(s string, args ...any) {
+	if len(p.reporters) == 0 {
+		return
+	}
+	msg := fmt.Sprintf(s, args...)
+	for _, r := range p.reporters {
+		r.OnLog(msg)
+	}
+}
+
+func (p *P0d) onPhase() {
+	//phases change on other goroutines, reporters only ever hear from the main loop.
+	for {
+		select {
+		case phase := <-p.phases:
+			for _, r := range p.reporters {
+				r.OnPhase(phase)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *P0d) onAttempt(ra ReqAtmpt) {
+	for _, r := range p.reporters {
+		r.OnAttempt(ra)
+	}
+}
+
+func (p *P0d) onSnapshot() {
+	p.onPhase()
+	for _, r := range p.reporters {
+		r.OnSnapshot(p.ReqStats)
+	}
+}
+
+func (p *P0d) onFinish() {
+	p.onPhase()
+	for _, r := range p.reporters {
+		r.OnFinish(p)
+	}
+}
+
+type consoleReporter struct {
+	p *P0d
+}
+
+func NewConsoleReporter(p *P0d) Reporter {
+	return &consoleReporter{p: p}
+}
+
+func (c *consoleReporter) OnStart(cfg Config) {
+	c.p.initLog()
+}
+
+func (c *consoleReporter) OnLog(msg string) {
+	log("%s", msg)
+}
+
+func (c *consoleReporter) OnPhase(phase TimerPhase) {}
+
+func (c *consoleReporter) OnAttempt(ra ReqAtmpt) {}
+
+func (c *consoleReporter) OnSnapshot(s *ReqStats) {
+	if c.p.liveWriters == nil {
+		c.p.initLiveWriters(9)
+	}
+	c.p.doLogLive()
+}
+
+func (c *consoleReporter) OnFinish(p *P0d) {
+	//interrupted before the race started, there is nothing to summarize.
+	if p.liveWriters == nil {
+		return
+	}
+	//call final log manually to prevent differences between summary and what's on screen in live log.
+	p.doLogLive()
+	p.liveWriters[0].(*uilive.Writer).Stop()
+	p.logSummary()
+	p.logVerdict()
+}
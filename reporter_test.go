@@ -0,0 +1,146 @@
+package p0d
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingReporter struct {
+	starts    int
+	logs      []string
+	phases    []TimerPhase
+	attempts  int64
+	snapshots int
+	finishes  int
+}
+
+func (c *countingReporter) OnStart(cfg Config) {
+	c.starts++
+}
+
+func (c *countingReporter) OnLog(msg string) {
+	c.logs = append(c.logs, msg)
+}
+
+func (c *countingReporter) OnPhase(phase TimerPhase) {
+	c.phases = append(c.phases, phase)
+}
+
+func (c *countingReporter) OnAttempt(ra ReqAtmpt) {
+	c.attempts++
+}
+
+func (c *countingReporter) OnSnapshot(s *ReqStats) {
+	c.snapshots++
+}
+
+func (c *countingReporter) OnFinish(p *P0d) {
+	c.finishes++
+}
+
+func newTestP0d(t *testing.T, url string, output string) *P0d {
+	p, e := NewP0dFromConfig(Config{
+		Req:  Req{Url: url},
+		Exec: Exec{DurationSeconds: 10, Concurrency: 2, SkipInetTest: true},
+	}, output)
+	if e != nil {
+		t.Fatalf("should create p0d, got %v", e)
+	}
+	return p
+}
+
+func TestRaceWithContext(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	//embedded runs must not write to stdout
+	stdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	p := newTestP0d(t, svr.URL, "")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, e := p.Race(ctx)
+
+	w.Close()
+	os.Stdout = stdout
+	if s := <-out; s != N {
+		t.Errorf("embedded run should be silent, got %q", s)
+	}
+
+	if e != nil {
+		t.Errorf("race should not error, got %v", e)
+	}
+	if !res.Interrupted || res.ExitCode != ExitInterrupted {
+		t.Errorf("cancelled run should be interrupted, got %v %d", res.Interrupted, res.ExitCode)
+	}
+	if res.ReqStats.ReqAtmpts == 0 || res.ID != p.ID {
+		t.Errorf("result should have stats, got %d reqs", res.ReqStats.ReqAtmpts)
+	}
+	if res.Time.Stop.Sub(res.Time.Start) > time.Second*5 {
+		t.Error("cancelled run should stop early")
+	}
+}
+
+func TestRaceWithReporter(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p := newTestP0d(t, svr.URL, "")
+	c := &countingReporter{}
+	p.AddReporter(c)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p.Race(ctx)
+
+	if c.starts != 1 || c.finishes != 1 || c.snapshots == 0 {
+		t.Errorf("reporter should see start, snapshots and finish, got %+v", c)
+	}
+	if c.attempts == 0 || c.attempts != p.ReqStats.ReqAtmpts {
+		t.Errorf("reporter should see every attempt, got %d want %d", c.attempts, p.ReqStats.ReqAtmpts)
+	}
+	if len(c.phases) == 0 || c.phases[len(c.phases)-1] != Done {
+		t.Errorf("reporter should see phases up to done, got %v", c.phases)
+	}
+	for i := 1; i < len(c.phases); i++ {
+		if c.phases[i] <= c.phases[i-1] {
+			t.Errorf("phases should only move forward, got %v", c.phases)
+		}
+	}
+	if len(c.logs) == 0 || !strings.Contains(c.logs[len(c.logs)-1], "exiting") {
+		t.Errorf("reporter should see log messages, got %v", c.logs)
+	}
+}
+
+func TestRaceOutputError(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p := newTestP0d(t, svr.URL, "./nope/testoutput.json")
+	res, e := p.Race(context.Background())
+	if e == nil {
+		t.Error("unwritable output should error")
+	}
+	if !res.Interrupted {
+		t.Error("unwritable output should stop the run")
+	}
+}
@@ -0,0 +1,199 @@
+package p0d
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"os"
+	"strconv"
+	"time"
+)
+
+const reqLogBuffer = 65536
+const reqLogWriteBuffer = 1 << 16
+
+const hdrLowestNs = int64(time.Microsecond)
+const hdrHighestNs = int64(time.Hour)
+const hdrSigFigs = 3
+const hdrMaxValueUnitRatio = float64(time.Millisecond)
+
+var reqLogHeader = []string{"startOffsetUs", "latencyUs", "correctedLatencyUs", "code", "bytesRead", "bytesWritten",
+	"error", "slot", "connReused", "req"}
+
+type reqLogEntry struct {
+	ra   ReqAtmpt
+	roll time.Time
+}
+
+type reqLog struct {
+	start    time.Time
+	entries  chan reqLogEntry
+	done     chan struct{}
+	csvFile  *os.File
+	csvBuf   *bufio.Writer
+	csv      *csv.Writer
+	hdrFile  *os.File
+	hdrBuf   *bufio.Writer
+	hdr      *HdrHistogram
+	interval time.Time
+	err      error
+}
+
+func (p *P0d) initReqLog() {
+	cfg := p.Config.Exec
+	if cfg.RequestLog == N && cfg.HdrLog == N {
+		return
+	}
+	rl := &reqLog{
+		start:    p.Time.Start,
+		interval: p.Time.Start,
+		entries:  make(chan reqLogEntry, reqLogBuffer),
+		done:     make(chan struct{}),
+	}
+	//a sink we can't create is off and fails the run, the other one still logs.
+	if cfg.RequestLog != N {
+		if f, e := os.Create(cfg.RequestLog); e != nil {
+			p.reqLogCreateErr(fmt.Sprintf("request log %s", cfg.RequestLog), e)
+		} else {
+			rl.initCsv(f)
+			p.logf("logging all requests to %s", Yellow(cfg.RequestLog))
+		}
+	}
+	if cfg.HdrLog != N {
+		if f, e := os.Create(cfg.HdrLog); e != nil {
+			p.reqLogCreateErr(fmt.Sprintf("hdr log %s", cfg.HdrLog), e)
+		} else {
+			rl.initHdr(f)
+			p.logf("logging latency histograms to %s", Yellow(cfg.HdrLog))
+		}
+	}
+	if rl.csv == nil && rl.hdr == nil {
+		return
+	}
+	p.reqLog = rl
+	go rl.run()
+}
+
+func (p *P0d) reqLogCreateErr(what string, e error) {
+	p.logf("%v", Red(fmt.Sprintf("unable to write %s: %s", what, e.Error())))
+	if p.outErr == nil {
+		p.outErr = e
+	}
+}
+
+func (rl *reqLog) initCsv(f *os.File) {
+	rl.csvFile = f
+	rl.csvBuf = bufio.NewWriterSize(f, reqLogWriteBuffer)
+	rl.csv = csv.NewWriter(rl.csvBuf)
+	rl.csv.Write(reqLogHeader)
+}
+
+func (rl *reqLog) initHdr(f *os.File) {
+	rl.hdrFile = f
+	rl.hdrBuf = bufio.NewWriterSize(f, reqLogWriteBuffer)
+	//latencies are recorded in nanoseconds from 1μs to 1h at 3 significant digits, plotted in milliseconds.
+	rl.hdr = NewHdrHistogram(hdrLowestNs, hdrHighestNs, hdrSigFigs)
+	secs := float64(rl.start.UnixMilli()) / 1000
+	fmt.Fprintf(rl.hdrBuf, "#[Logged with p0d %s]\n", Version)
+	fmt.Fprintf(rl.hdrBuf, "#[Histogram log format version 1.3]\n")
+	fmt.Fprintf(rl.hdrBuf, "#[StartTime: %.3f (seconds since epoch), %s]\n", secs, rl.start.Format(time.UnixDate))
+	fmt.Fprintf(rl.hdrBuf, "#[BaseTime: %.3f (seconds since epoch)]\n", secs)
+	fmt.Fprintf(rl.hdrBuf, "\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n")
+}
+
+func (p *P0d) logReqAtmpt(ra ReqAtmpt) {
+	if p.reqLog == nil {
+		return
+	}
+	//the writer is buffered well beyond a second of requests, it only blocks if the disk can't keep up.
+	p.reqLog.entries <- reqLogEntry{ra: ra}
+}
+
+func (p *P0d) rollReqLog(now time.Time) {
+	if p.reqLog == nil {
+		return
+	}
+	p.reqLog.entries <- reqLogEntry{roll: now}
+}
+
+func (p *P0d) stopReqLog(now time.Time) {
+	if p.reqLog == nil {
+		return
+	}
+	p.rollReqLog(now)
+	close(p.reqLog.entries)
+	<-p.reqLog.done
+	if p.reqLog.err != nil {
+		p.logf("%v", Red(fmt.Sprintf("unable to write request log: %s", p.reqLog.err.Error())))
+		if p.outErr == nil {
+			p.outErr = p.reqLog.err
+		}
+	}
+}
+
+func (rl *reqLog) run() {
+	defer close(rl.done)
+	for e := range rl.entries {
+		if !e.roll.IsZero() {
+			rl.writeInterval(e.roll)
+		} else {
+			rl.write(e.ra)
+		}
+	}
+	if rl.csv != nil {
+		rl.csv.Flush()
+		rl.check(rl.csv.Error())
+		rl.check(rl.csvBuf.Flush())
+		rl.check(rl.csvFile.Close())
+	}
+	if rl.hdr != nil {
+		rl.check(rl.hdrBuf.Flush())
+		rl.check(rl.hdrFile.Close())
+	}
+}
+
+func (rl *reqLog) check(e error) {
+	if e != nil && rl.err == nil {
+		rl.err = e
+	}
+}
+
+func (rl *reqLog) write(ra ReqAtmpt) {
+	if rl.hdr != nil {
+		rl.hdr.Record(ra.ElpsdNs.Nanoseconds())
+	}
+	if rl.csv == nil {
+		return
+	}
+	cns := ra.ElpsdCorrectedNs
+	if cns < ra.ElpsdNs {
+		cns = ra.ElpsdNs
+	}
+	rl.check(rl.csv.Write([]string{
+		strconv.FormatInt(ra.Start.Sub(rl.start).Microseconds(), 10),
+		strconv.FormatInt(ra.ElpsdNs.Microseconds(), 10),
+		strconv.FormatInt(cns.Microseconds(), 10),
+		strconv.Itoa(ra.ResCode),
+		strconv.FormatInt(ra.ResBytes, 10),
+		strconv.FormatInt(ra.ReqBytes, 10),
+		ra.ResErr,
+		strconv.Itoa(ra.Slot),
+		strconv.FormatBool(ra.ConnReused),
+		ra.ReqName,
+	}))
+}
+
+func (rl *reqLog) writeInterval(now time.Time) {
+	if rl.hdr == nil {
+		return
+	}
+	_, e := fmt.Fprintf(rl.hdrBuf, "%.3f,%.3f,%.3f,%s\n",
+		rl.interval.Sub(rl.start).Seconds(),
+		now.Sub(rl.interval).Seconds(),
+		float64(rl.hdr.Max())/hdrMaxValueUnitRatio,
+		rl.hdr.EncodeCompressed())
+	rl.check(e)
+	rl.hdr.reset()
+	rl.interval = now
+}
@@ -0,0 +1,84 @@
+package p0d
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRaceWithRequestLog(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get.yml", "")
+	p.Config.Exec.RequestLog = "testrequests.csv"
+	p.Config.Exec.HdrLog = "testlatency.hlog"
+	defer os.Remove(p.Config.Exec.RequestLog)
+	defer os.Remove(p.Config.Exec.HdrLog)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p.Config.Req.Url = svr.URL
+	p.Config.Exec.DurationSeconds = 1
+	p.Race(context.Background())
+
+	f, _ := os.Open(p.Config.Exec.RequestLog)
+	defer f.Close()
+	rows, e := csv.NewReader(f).ReadAll()
+	if e != nil {
+		t.Fatalf("request log should be csv, got %v", e)
+	}
+	if strings.Join(rows[0], ",") != strings.Join(reqLogHeader, ",") {
+		t.Errorf("request log header incorrect, got %v", rows[0])
+	}
+	if int64(len(rows)-1) != p.ReqStats.ReqAtmpts {
+		t.Errorf("request log should have every attempt, got %d of %d", len(rows)-1, p.ReqStats.ReqAtmpts)
+	}
+	if rows[1][3] != "200" || rows[1][7] == N {
+		t.Errorf("request log row incorrect, got %v", rows[1])
+	}
+
+	h, _ := os.Open(p.Config.Exec.HdrLog)
+	defer h.Close()
+	intervals := 0
+	sc := bufio.NewScanner(h)
+	sc.Buffer(make([]byte, 1<<20), 1<<20)
+	for sc.Scan() {
+		l := sc.Text()
+		if strings.HasPrefix(l, "#") || strings.HasPrefix(l, `"`) {
+			continue
+		}
+		if c := strings.Split(l, ","); len(c) != 4 || !strings.HasPrefix(c[3], "HISTF") {
+			t.Errorf("hdr log interval incorrect, got %v", l)
+		}
+		intervals++
+	}
+	if intervals == 0 {
+		t.Error("hdr log should have intervals")
+	}
+}
+
+func TestInitReqLogBadPath(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get.yml", "")
+	p.Config.Exec.RequestLog = "./nope/testrequests.csv"
+	p.Config.Exec.HdrLog = "testlatency.hlog"
+	defer os.Remove(p.Config.Exec.HdrLog)
+
+	p.initReqLog()
+	if p.outErr == nil {
+		t.Error("bad request log path should fail the run")
+	}
+	if p.reqLog == nil || p.reqLog.csv != nil || p.reqLog.hdr == nil {
+		t.Fatal("hdr log should still be on")
+	}
+	p.stopReqLog(p.Time.Start)
+	if _, e := os.Stat(p.Config.Exec.HdrLog); e != nil {
+		t.Errorf("hdr log should be written, got %v", e)
+	}
+}
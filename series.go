@@ -0,0 +1,149 @@
+package p0d
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type Bucket struct {
+	Start                  time.Time
+	ElpsdNs                time.Duration
+	Phase                  string
+	ReqAtmpts              int64
+	ReqAtmptsPSec          float64
+	ElpsdAtmptLatencyNsP50 int64
+	ElpsdAtmptLatencyNsP90 int64
+	ElpsdAtmptLatencyNsP99 int64
+	SumBytesRead           int64
+	SumBytesWritten        int64
+	SumErrors              int
+	ErrorTypes             map[string]int
+	OpenConns              int
+
+	latency *Quantile
+}
+
+func newBucket(now time.Time) *Bucket {
+	return &Bucket{
+		Start:      now,
+		ErrorTypes: make(map[string]int),
+		latency:    NewQuantileWithCompression(100),
+	}
+}
+
+func (b *Bucket) add(atmpt ReqAtmpt) {
+	b.ReqAtmpts++
+	b.SumBytesRead += atmpt.ResBytes
+	b.SumBytesWritten += atmpt.ReqBytes
+	b.latency.Add(float64(atmpt.ElpsdNs.Nanoseconds()), 1)
+	if atmpt.ResErr != "" {
+		b.SumErrors++
+		b.ErrorTypes[atmpt.ResErr]++
+	}
+}
+
+func (b *Bucket) close(now time.Time, phase string, openConns int) {
+	b.ElpsdNs = now.Sub(b.Start)
+	b.Phase = phase
+	b.OpenConns = openConns
+	if b.ElpsdNs > 0 {
+		b.ReqAtmptsPSec = float64(b.ReqAtmpts) / b.ElpsdNs.Seconds()
+	}
+	//an empty bucket has no latency, not NaN
+	q := func(v float64) int64 {
+		if b.ReqAtmpts == 0 {
+			return 0
+		}
+		return int64(math.Ceil(b.latency.Quantile(v)))
+	}
+	b.ElpsdAtmptLatencyNsP50 = q(0.5)
+	b.ElpsdAtmptLatencyNsP90 = q(0.9)
+	b.ElpsdAtmptLatencyNsP99 = q(0.99)
+	b.latency = nil
+}
+
+func (b *Bucket) merge(o *Bucket) {
+	//buckets only keep their percentiles, so these are weighted by attempts rather than merged exactly.
+	if n := b.ReqAtmpts + o.ReqAtmpts; n > 0 {
+		w := func(a int64, c int64) int64 {
+			return (a*b.ReqAtmpts + c*o.ReqAtmpts) / n
+		}
+		b.ElpsdAtmptLatencyNsP50 = w(b.ElpsdAtmptLatencyNsP50, o.ElpsdAtmptLatencyNsP50)
+		b.ElpsdAtmptLatencyNsP90 = w(b.ElpsdAtmptLatencyNsP90, o.ElpsdAtmptLatencyNsP90)
+		b.ElpsdAtmptLatencyNsP99 = w(b.ElpsdAtmptLatencyNsP99, o.ElpsdAtmptLatencyNsP99)
+	}
+	b.ReqAtmpts += o.ReqAtmpts
+	b.ReqAtmptsPSec += o.ReqAtmptsPSec
+	b.SumBytesRead += o.SumBytesRead
+	b.SumBytesWritten += o.SumBytesWritten
+	b.SumErrors += o.SumErrors
+	b.OpenConns += o.OpenConns
+	if o.ElpsdNs > b.ElpsdNs {
+		b.ElpsdNs = o.ElpsdNs
+	}
+	for k, v := range o.ErrorTypes {
+		if b.ErrorTypes == nil {
+			b.ErrorTypes = make(map[string]int)
+		}
+		b.ErrorTypes[k] += v
+	}
+}
+
+func (b *Bucket) lines(o *Bucket) bool {
+	//runs on different machines never start at exactly the same time, buckets within half an interval line up.
+	d := b.Start.Sub(o.Start)
+	if d < 0 {
+		d = -d
+	}
+	l := b.ElpsdNs
+	if o.ElpsdNs > l {
+		l = o.ElpsdNs
+	}
+	return d <= l/2
+}
+
+func findBucket(bs []*Bucket, b *Bucket) int {
+	for i, c := range bs {
+		if c.lines(b) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *ReqStats) mergeSeries(o []*Bucket) {
+	//a run that started late or missed a bucket must not shift the others, so buckets line up by start time.
+	for _, b := range o {
+		if i := findBucket(s.Series, b); i >= 0 {
+			s.Series[i].merge(b)
+		} else {
+			s.Series = append(s.Series, b)
+		}
+	}
+	sort.SliceStable(s.Series, func(i, j int) bool {
+		return s.Series[i].Start.Before(s.Series[j].Start)
+	})
+}
+
+func (s *ReqStats) openBucket(now time.Time) {
+	s.bucket = newBucket(now)
+}
+
+func (s *ReqStats) closeBucket(now time.Time, phase string, openConns int) *Bucket {
+	b := s.bucket
+	if b == nil {
+		return nil
+	}
+	b.close(now, phase, openConns)
+	s.Series = append(s.Series, b)
+	s.bucket = nil
+	return b
+}
+
+func (p *P0d) rollSeries(now time.Time) {
+	//the phase and open conns are what we see at the end of each bucket
+	p.ReqStats.closeBucket(now, timerPhaseNames[p.Time.Phase], p.getOSOpenConns().OpenConns)
+	p.ReqStats.openBucket(now)
+	p.rollReqLog(now)
+}
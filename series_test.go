@@ -0,0 +1,43 @@
+package p0d
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesBuckets(t *testing.T) {
+	s := NewReqStats(Config{})
+	now := time.Now()
+	s.setStart(now)
+	s.openBucket(now)
+
+	s.update(ReqAtmpt{ElpsdNs: time.Millisecond, ResBytes: 10, ReqBytes: 5}, now, Config{})
+	s.update(ReqAtmpt{ElpsdNs: time.Millisecond * 3, ResErr: "connection refused"}, now, Config{})
+	s.closeBucket(now.Add(time.Second), "main", 4)
+	s.openBucket(now.Add(time.Second))
+	s.closeBucket(now.Add(time.Second*2), "rampdown", 0)
+
+	if len(s.Series) != 2 {
+		t.Fatalf("should have 2 buckets, got %d", len(s.Series))
+	}
+	b := s.Series[0]
+	if b.ReqAtmpts != 2 || b.ReqAtmptsPSec != 2 || b.Phase != "main" || b.OpenConns != 4 {
+		t.Errorf("bucket counts incorrect, got %+v", b)
+	}
+	if b.SumBytesRead != 10 || b.SumBytesWritten != 5 || b.SumErrors != 1 || b.ErrorTypes["connection refused"] != 1 {
+		t.Errorf("bucket bytes or errors incorrect, got %+v", b)
+	}
+	if b.ElpsdAtmptLatencyNsP50 < int64(time.Millisecond) || b.ElpsdAtmptLatencyNsP99 < b.ElpsdAtmptLatencyNsP50 {
+		t.Errorf("bucket latency incorrect, got %v %v", b.ElpsdAtmptLatencyNsP50, b.ElpsdAtmptLatencyNsP99)
+	}
+	if e := s.Series[1]; e.ReqAtmpts != 0 || e.ElpsdAtmptLatencyNsP99 != 0 || e.Phase != "rampdown" {
+		t.Errorf("empty bucket incorrect, got %+v", e)
+	}
+}
+
+func TestSeriesSecondsDefault(t *testing.T) {
+	p := NewP0dWithValues(1, 3, "http://localhost/", "1.1", "", true)
+	if p.Config.Exec.SeriesSeconds != 1 {
+		t.Error("series interval should default to 1s")
+	}
+}
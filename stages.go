@@ -0,0 +1,151 @@
+package p0d
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const stageUpdateInterval = time.Millisecond * 100
+
+func (p *P0d) stageAt(now time.Time) (int, float64) {
+	//each stage moves linearly from the previous stage's target to its own over its duration.
+	elapsed := now.Sub(p.Time.Start).Seconds()
+	from := 0.0
+	start := 0.0
+	for i, s := range p.Config.Exec.Stages {
+		to := float64(s.Concurrency)
+		if p.Config.isRateMode() {
+			to = float64(s.Rate)
+		}
+		end := start + float64(s.DurationSeconds)
+		if elapsed < end {
+			if elapsed < start {
+				elapsed = start
+			}
+			return i, from + (to-from)*(elapsed-start)/float64(s.DurationSeconds)
+		}
+		from = to
+		start = end
+	}
+	return len(p.Config.Exec.Stages) - 1, from
+}
+
+func (p *P0d) initStageReqAtmpts(done chan struct{}, ras chan ReqAtmpt) {
+	p.setTimerPhase(RampUp)
+	p.runningThreads = make([]int32, p.Config.Exec.Concurrency)
+
+	if p.Config.isRateMode() {
+		//the pool is fixed, the scheduler follows the stages with its offered load.
+		sched := make(chan time.Time)
+		for i := 0; i < p.Config.Exec.Concurrency; i++ {
+			go p.doRateReqAtmpts(i, ras, p.stopThreads[i], sched)
+		}
+		p.initRateScheduler(p.stopSched, sched)
+	}
+
+	//don't block because execution continues on to live updates
+	go func() {
+		last := -1
+	Stages:
+		for {
+			select {
+			case <-done:
+				break Stages
+			default:
+				i, target := p.stageAt(time.Now())
+				if i != last {
+					//the main loop owns timer state, hand it the new stage.
+					p.publishStage(i)
+					last = i
+				}
+				if !p.Config.isRateMode() {
+					p.setActiveThreads(int(math.Round(target)), ras)
+				}
+				time.Sleep(stageUpdateInterval)
+			}
+		}
+	}()
+}
+
+func (p *P0d) publishStage(i int) {
+	//only the latest stage counts.
+	select {
+	case <-p.stage:
+	default:
+	}
+	select {
+	case p.stage <- i:
+	default:
+	}
+}
+
+func (p *P0d) setStage(i int) {
+	p.Time.Stage = p.Config.Exec.Stages[i].Name
+	if i > 0 {
+		p.setTimerPhase(Main)
+	}
+
+	//the last stage counts as ramp down if it's heading for a lower target than the one before it
+	last := len(p.Config.Exec.Stages) - 1
+	if i > 0 && i == last {
+		c, pc := p.Config.Exec.Stages[i], p.Config.Exec.Stages[i-1]
+		if c.Concurrency < pc.Concurrency || c.Rate < pc.Rate {
+			p.setTimerPhase(RampDown)
+		}
+	}
+}
+
+func (p *P0d) setActiveThreads(n int, ras chan ReqAtmpt) {
+	p.threadsLock.Lock()
+	defer p.threadsLock.Unlock()
+	if n > len(p.stopThreads) {
+		n = len(p.stopThreads)
+	}
+	for p.activeThreads < n {
+		i := p.activeThreads
+		//a goroutine we stopped earlier in this slot may still be finishing its last request, wait for it.
+		if !atomic.CompareAndSwapInt32(&p.runningThreads[i], 0, 1) {
+			break
+		}
+		go func() {
+			p.doReqAtmpts(i, ras, p.stopThreads[i])
+			atomic.StoreInt32(&p.runningThreads[i], 0)
+		}()
+		p.activeThreads++
+	}
+	for p.activeThreads > n {
+		p.activeThreads--
+		p.stopThreads[p.activeThreads] <- struct{}{}
+	}
+}
+
+func (p *P0d) canResize() bool {
+	//ramps, stages and the rate scheduler bring their own targets, only a closed loop main phase is ours to change.
+	return !p.Config.hasStages() && !p.Config.isRateMode() && p.isTimerPhase(Main)
+}
+
+func (p *P0d) SetConcurrency(n int) bool {
+	if !p.canResize() {
+		return false
+	}
+	if n < 1 {
+		n = 1
+	}
+	//the main loop applies this, only the latest target counts.
+	select {
+	case <-p.resize:
+	default:
+	}
+	select {
+	case p.resize <- n:
+	default:
+	}
+	return true
+}
+
+func (p *P0d) ActiveConcurrency() int {
+	p.threadsLock.Lock()
+	defer p.threadsLock.Unlock()
+	return p.activeThreads
+}
@@ -0,0 +1,195 @@
+package p0d
+
+import (
+	"context"
+	"fmt"
+	"github.com/acarl005/stripansi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateStages(t *testing.T) {
+	cfg := loadConfigFromFile("./examples/config_get_stages.yml")
+	cfg.validate()
+
+	if cfg.Exec.DurationSeconds != 672 {
+		t.Errorf("duration should be sum of stages, got %v", cfg.Exec.DurationSeconds)
+	}
+	if cfg.Exec.Concurrency != 800 {
+		t.Errorf("concurrency should be max of stages, got %v", cfg.Exec.Concurrency)
+	}
+	if cfg.Exec.RampSeconds != 0 {
+		t.Error("stages should not have default ramp")
+	}
+	if cfg.isRateMode() {
+		t.Error("concurrency stages should not be rate mode")
+	}
+
+	cfg2 := Config{
+		Req: Req{Url: "http://localhost:8080/blah"},
+		Exec: Exec{
+			Concurrency: 16,
+			Stages: []Stage{
+				{DurationSeconds: 2, Rate: 100},
+				{DurationSeconds: 2, Rate: 400},
+			},
+		},
+	}
+	cfg2.validate()
+	if cfg2.Exec.Rate != 400 {
+		t.Errorf("rate should be max of stages, got %v", cfg2.Exec.Rate)
+	}
+	if cfg2.Exec.Concurrency != 16 {
+		t.Error("rate stages should keep connection pool size")
+	}
+	if cfg2.Exec.Stages[1].Name != "stage 2" {
+		t.Errorf("default stage name incorrect, got %v", cfg2.Exec.Stages[1].Name)
+	}
+}
+
+func TestStageAt(t *testing.T) {
+	p := P0d{
+		Config: Config{
+			Exec: Exec{
+				Stages: []Stage{
+					{Name: "up", DurationSeconds: 10, Concurrency: 100},
+					{Name: "hold", DurationSeconds: 10, Concurrency: 100},
+					{Name: "spike", DurationSeconds: 2, Concurrency: 500},
+					{Name: "down", DurationSeconds: 8, Concurrency: 0},
+				},
+			},
+		},
+	}
+	p.Time.Start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type stageTest struct {
+		deltaSecond float64
+		wantStage   int
+		wantTarget  float64
+	}
+
+	tests := []stageTest{
+		{deltaSecond: 0, wantStage: 0, wantTarget: 0},
+		{deltaSecond: 5, wantStage: 0, wantTarget: 50},
+		{deltaSecond: 10, wantStage: 1, wantTarget: 100},
+		{deltaSecond: 15, wantStage: 1, wantTarget: 100},
+		{deltaSecond: 21, wantStage: 2, wantTarget: 300},
+		{deltaSecond: 26, wantStage: 3, wantTarget: 250},
+		{deltaSecond: 30, wantStage: 3, wantTarget: 0},
+		{deltaSecond: 40, wantStage: 3, wantTarget: 0},
+	}
+
+	for _, tc := range tests {
+		now := p.Time.Start.Add(time.Duration(tc.deltaSecond * float64(time.Second)))
+		gotStage, gotTarget := p.stageAt(now)
+		if gotStage != tc.wantStage || gotTarget != tc.wantTarget {
+			t.Errorf("bad stage for deltaSeconds %v, want %v/%v got %v/%v",
+				tc.deltaSecond, tc.wantStage, tc.wantTarget, gotStage, gotTarget)
+		}
+	}
+}
+
+func TestSetStageTimerPhase(t *testing.T) {
+	p := P0d{
+		Time: Time{Phase: RampUp},
+		Config: Config{
+			Exec: Exec{
+				Stages: []Stage{
+					{Name: "up", DurationSeconds: 10, Concurrency: 100},
+					{Name: "hold", DurationSeconds: 10, Concurrency: 100},
+					{Name: "down", DurationSeconds: 10, Concurrency: 0},
+				},
+			},
+		},
+	}
+
+	p.setStage(0)
+	if !p.isTimerPhase(RampUp) || p.Time.Stage != "up" {
+		t.Error("first stage should ramp up")
+	}
+	p.setStage(1)
+	if !p.isTimerPhase(Main) || p.Time.Stage != "hold" {
+		t.Error("second stage should be main")
+	}
+	p.setStage(2)
+	if !p.isTimerPhase(RampDown) || p.Time.Stage != "down" {
+		t.Error("last stage should ramp down")
+	}
+}
+
+func TestProgressBarMarkStages(t *testing.T) {
+	layout := "2006-01-02T15:04:05.000Z"
+	p := P0d{
+		Config: Config{
+			Exec: Exec{
+				DurationSeconds: 30,
+				Stages: []Stage{
+					{DurationSeconds: 10},
+					{DurationSeconds: 20},
+				},
+			},
+		},
+	}
+	p.Time.Start, _ = time.Parse(layout, "2022-01-01T00:00:00.000Z")
+
+	pb := ProgressBar{
+		maxSecs:    30,
+		size:       30,
+		chunkProps: make([]ChunkProps, 30),
+	}
+	pb.markStages(&p)
+
+	if !pb.chunkProps[10].isStageStart {
+		t.Error("stage boundary not marked")
+	}
+	bar := stripansi.Strip(pb.render(p.Time.Start, &p))
+	if strings.Count(bar, STAGE) != 1 {
+		t.Errorf("stage boundary not rendered, got %v", bar)
+	}
+}
+
+func TestRaceWithStages(t *testing.T) {
+	p := NewP0dFromFile("./examples/config_get.yml", "")
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	//we hack the config's URL to point at our mock server so we can execute the test
+	p.Config.Req.Url = svr.URL
+	p.Config.Exec.Stages = []Stage{
+		{Name: "up", DurationSeconds: 1, Concurrency: 4},
+		{Name: "down", DurationSeconds: 1, Concurrency: 0},
+	}
+	p.Config.Exec.DurationSeconds = 2
+	p.Race(context.Background())
+
+	if p.ReqStats.ReqAtmpts == 0 {
+		t.Error("stages should have sent requests")
+	}
+	if p.Time.Stage != "down" {
+		t.Errorf("should have finished in last stage, got %v", p.Time.Stage)
+	}
+}
+
+func TestSetConcurrency(t *testing.T) {
+	p, _ := NewP0dFromConfig(Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{Concurrency: 4}}, "")
+	if p.SetConcurrency(2) {
+		t.Error("concurrency should not change before the main phase")
+	}
+	p.setTimerPhase(Main)
+	if !p.SetConcurrency(2) || !p.SetConcurrency(3) || <-p.resize != 3 {
+		t.Error("latest concurrency target should be applied in the main phase")
+	}
+
+	s, _ := NewP0dFromConfig(Config{Req: Req{Url: "http://localhost/"}, Exec: Exec{Concurrency: 4,
+		Stages: []Stage{{Name: "a", DurationSeconds: 5, Concurrency: 4}}}}, "")
+	s.setTimerPhase(Main)
+	if s.SetConcurrency(2) {
+		t.Error("concurrency should follow stages")
+	}
+}
@@ -1,7 +1,10 @@
 package p0d
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"github.com/axiomhq/variance"
 	"github.com/showwin/speedtest-go/speedtest"
 	"github.com/simonmittag/procspy"
@@ -33,28 +36,107 @@ func NewSample() Sample {
 }
 
 type ReqStats struct {
-	Start                        time.Time
-	ElpsdNs                      time.Duration
-	ReqAtmpts                    int64
-	CurReqAtmptsPSec             int64
-	MeanReqAtmptsPSec            int64
-	MaxReqAtmptsPSec             int64
-	CurBytesReadPSec             int64
-	SumBytesRead                 int64
-	MeanBytesReadPSec            int64
-	MaxBytesReadPSec             int64
-	CurBytesWrittenPSec          int64
-	SumBytesWritten              int64
-	MeanBytesWrittenPSec         int64
-	MaxBytesWrittenPSec          int64
-	ElpsdAtmptLatencyNsQuantiles *Quantile
-	ElpsdAtmptLatencyNs          *Welford
-	SumMatchingResponseCodes     int
-	PctMatchingResponseCodes     float32
-	Sample                       Sample
-	SumErrors                    int
-	PctErrors                    float32
-	ErrorTypes                   map[string]int
+	Start                                 time.Time
+	ElpsdNs                               time.Duration
+	ReqAtmpts                             int64
+	CurReqAtmptsPSec                      int64
+	MeanReqAtmptsPSec                     int64
+	MaxReqAtmptsPSec                      int64
+	SumDroppedReqAtmpts                   int64
+	PctDroppedReqAtmpts                   float32
+	CurBytesReadPSec                      int64
+	SumBytesRead                          int64
+	MeanBytesReadPSec                     int64
+	MaxBytesReadPSec                      int64
+	CurBytesWrittenPSec                   int64
+	SumBytesWritten                       int64
+	MeanBytesWrittenPSec                  int64
+	MaxBytesWrittenPSec                   int64
+	ElpsdAtmptLatencyNsQuantiles          *Quantile
+	ElpsdAtmptCorrectedLatencyNsQuantiles *Quantile
+	ElpsdAtmptLatencyNs                   *Welford
+	ElpsdDNSNsQuantiles                   *Quantile
+	ElpsdConnectNsQuantiles               *Quantile
+	ElpsdTLSNsQuantiles                   *Quantile
+	ElpsdTTFBNsQuantiles                  *Quantile
+	ElpsdDownloadNsQuantiles              *Quantile
+	SumConnReused                         int
+	PctConnReused                         float32
+	SumMatchingResponseCodes              int
+	PctMatchingResponseCodes              float32
+	SumAssertionFailures                  int
+	PctAssertionFailures                  float32
+	AssertionFailTypes                    map[string]int
+	Sample                                Sample
+	SumErrors                             int
+	PctErrors                             float32
+	ErrorTypes                            map[string]int
+	Reqs                                  map[string]*ReqStats
+	JourneyAtmpts                         int64
+	SumJourneySuccess                     int
+	PctJourneySuccess                     float32
+	ElpsdJourneyLatencyNsQuantiles        *Quantile
+	JourneyErrorTypes                     map[string]int
+	Series                                []*Bucket
+
+	bucket *Bucket
+}
+
+func NewReqStats(cfg Config) *ReqStats {
+	s := &ReqStats{
+		ErrorTypes:                            make(map[string]int),
+		AssertionFailTypes:                    make(map[string]int),
+		Sample:                                NewSample(),
+		ElpsdAtmptLatencyNsQuantiles:          cfg.newQuantile(),
+		ElpsdAtmptCorrectedLatencyNsQuantiles: cfg.newQuantile(),
+		ElpsdAtmptLatencyNs:                   NewWelford(),
+		ElpsdDNSNsQuantiles:                   cfg.newQuantile(),
+		ElpsdConnectNsQuantiles:               cfg.newQuantile(),
+		ElpsdTLSNsQuantiles:                   cfg.newQuantile(),
+		ElpsdTTFBNsQuantiles:                  cfg.newQuantile(),
+		ElpsdDownloadNsQuantiles:              cfg.newQuantile(),
+		ElpsdJourneyLatencyNsQuantiles:        cfg.newQuantile(),
+	}
+	//we keep stats per request template or scenario step, as well as in aggregate.
+	if cfg.hasReqTemplates() {
+		s.Reqs = make(map[string]*ReqStats)
+		for _, r := range cfg.reqs() {
+			s.Reqs[r.Name] = NewReqStats(Config{Exec: cfg.Exec})
+		}
+	}
+	return s
+}
+
+func (s *ReqStats) renew() *ReqStats {
+	//fresh counters on the same histograms, cleared. used for windows that restart every second.
+	n := &ReqStats{
+		ErrorTypes:                            make(map[string]int),
+		AssertionFailTypes:                    make(map[string]int),
+		Sample:                                NewSample(),
+		ElpsdAtmptLatencyNsQuantiles:          s.ElpsdAtmptLatencyNsQuantiles.reset(),
+		ElpsdAtmptCorrectedLatencyNsQuantiles: s.ElpsdAtmptCorrectedLatencyNsQuantiles.reset(),
+		ElpsdAtmptLatencyNs:                   NewWelford(),
+		ElpsdDNSNsQuantiles:                   s.ElpsdDNSNsQuantiles.reset(),
+		ElpsdConnectNsQuantiles:               s.ElpsdConnectNsQuantiles.reset(),
+		ElpsdTLSNsQuantiles:                   s.ElpsdTLSNsQuantiles.reset(),
+		ElpsdTTFBNsQuantiles:                  s.ElpsdTTFBNsQuantiles.reset(),
+		ElpsdDownloadNsQuantiles:              s.ElpsdDownloadNsQuantiles.reset(),
+		ElpsdJourneyLatencyNsQuantiles:        s.ElpsdJourneyLatencyNsQuantiles.reset(),
+	}
+	if s.Reqs != nil {
+		n.Reqs = make(map[string]*ReqStats, len(s.Reqs))
+		for k, rs := range s.Reqs {
+			n.Reqs[k] = rs.renew()
+		}
+	}
+	return n
+}
+
+func (s *ReqStats) setStart(now time.Time) {
+	s.Start = now
+	for _, rs := range s.Reqs {
+		rs.setStart(now)
+	}
 }
 
 type Welford struct {
@@ -111,51 +193,175 @@ func (w *Welford) MarshalJSON() ([]byte, error) {
 	m["stddev"] = w.Stddev()
 	m["cv"] = w.Cv()
 	m["stderr"] = w.Stderr()
+	m["n"] = float64(w.s.NumDataValues())
 	return json.Marshal(m)
 }
 
+func (w *Welford) UnmarshalJSON(b []byte) error {
+	var m map[string]float64
+	if e := json.Unmarshal(b, &m); e != nil {
+		return e
+	}
+	//rebuild the running state from what we wrote, every value had weight 1.
+	n := m["n"]
+	s := 0.0
+	if n > 1 {
+		s = m["stddev"] * m["stddev"] * (n - 1)
+	}
+	st := bytes.Buffer{}
+	binary.Write(&st, binary.BigEndian, uint64(n))
+	binary.Write(&st, binary.BigEndian, []float64{m["mean"], n, n, s})
+	w.s = variance.New()
+	_, e := w.s.ReadFrom(&st)
+	return e
+}
+
+func (w *Welford) Merge(o *Welford) {
+	if o.s.NumDataValues() == 0 {
+		return
+	}
+	w.s.Merge(o.s)
+}
+
 type Quantile struct {
-	t *tdigest.TDigest
+	t           *tdigest.TDigest
+	h           *HdrHistogram
+	compression float64
+	n           int64
+	min         float64
+	max         float64
 }
 
+var quantileKeys = []string{"min", "p10", "p16", "p25", "p50", "p75", "p84", "p90", "p99", "p99.9", "p99.99", "max"}
+
+var quantileValues = map[string]float64{"min": 0, "p10": 0.1, "p16": 0.16, "p25": 0.25, "p50": 0.5, "p75": 0.75,
+	"p84": 0.84, "p90": 0.9, "p99": 0.99, "p99.9": 0.999, "p99.99": 0.9999, "max": 1}
+
 func NewQuantile() *Quantile {
+	return NewQuantileWithCompression(100)
+}
+
+func NewQuantileWithCompression(compression float64) *Quantile {
 	return &Quantile{
-		t: tdigest.New(),
+		t:           tdigest.NewWithCompression(compression),
+		compression: compression,
 	}
 }
 
-func NewQuantileWithCompression(compression float64) *Quantile {
+func NewHdrQuantile(sigFigs int) *Quantile {
 	return &Quantile{
-		t: tdigest.NewWithCompression(compression),
+		h: NewHdrHistogram(hdrLowestNs, hdrHighestNs, sigFigs),
 	}
 }
 
 func (q *Quantile) Add(val float64, weight int) *Quantile {
-	q.t.Add(val, weight)
+	//min and max are kept exact, whatever the backend does with the rest.
+	if q.n == 0 || val < q.min {
+		q.min = val
+	}
+	if q.n == 0 || val > q.max {
+		q.max = val
+	}
+	q.n += int64(weight)
+	if q.h != nil {
+		q.h.RecordN(int64(val), int64(weight))
+	} else {
+		q.t.Add(val, weight)
+	}
+	return q
+}
+
+func (q *Quantile) reset() *Quantile {
+	//hdr counts are large and allocated up front, clear them instead of starting over.
+	if q.h != nil {
+		q.h.reset()
+	} else {
+		q.t = tdigest.NewWithCompression(q.compression)
+	}
+	q.n, q.min, q.max = 0, 0, 0
 	return q
 }
 
 func (q *Quantile) Quantile(v float64) float64 {
+	if q.n == 0 {
+		return math.NaN()
+	}
+	if v <= 0 {
+		return q.min
+	}
+	if v >= 1 {
+		return q.max
+	}
+	if q.h != nil {
+		//hdr has the highest value equivalent at its precision, we know better at the edges.
+		return math.Min(math.Max(float64(q.h.ValueAtQuantile(v)), q.min), q.max)
+	}
 	return q.t.Quantile(v)
 }
 
+func (q *Quantile) Merge(o *Quantile) error {
+	if q.h == nil || o.h == nil {
+		return fmt.Errorf("only hdr quantiles can be merged exactly")
+	}
+	if o.n == 0 {
+		return nil
+	}
+	if q.n == 0 || o.min < q.min {
+		q.min = o.min
+	}
+	if q.n == 0 || o.max > q.max {
+		q.max = o.max
+	}
+	q.n += o.n
+	q.h.Merge(o.h)
+	return nil
+}
+
 func (q *Quantile) MarshalJSON() ([]byte, error) {
-	m := make(map[string]int64)
-	m["min"] = int64(math.Ceil(q.t.Quantile(0)))
-	m["p10"] = int64(math.Ceil(q.t.Quantile(0.1)))
-	m["p16"] = int64(math.Ceil(q.t.Quantile(0.16)))
-	m["p25"] = int64(math.Ceil(q.t.Quantile(0.25)))
-	m["p50"] = int64(math.Ceil(q.t.Quantile(0.50)))
-	m["p75"] = int64(math.Ceil(q.t.Quantile(0.75)))
-	m["p84"] = int64(math.Ceil(q.t.Quantile(0.84)))
-	m["p90"] = int64(math.Ceil(q.t.Quantile(0.90)))
-	m["p99"] = int64(math.Ceil(q.t.Quantile(0.99)))
-	m["max"] = int64(math.Ceil(q.t.Quantile(1)))
+	m := make(map[string]any)
+	for _, k := range quantileKeys {
+		v := q.Quantile(quantileValues[k])
+		if math.IsNaN(v) {
+			v = 0
+		}
+		m[k] = int64(math.Ceil(v))
+	}
+	//hdr quantiles carry the whole histogram, so runs can be merged later.
+	if q.h != nil {
+		m["hdr"] = q.h.EncodeCompressed()
+	}
 	return json.Marshal(m)
 }
 
+func (q *Quantile) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if e := json.Unmarshal(b, &m); e != nil {
+		return e
+	}
+	raw, ok := m["hdr"]
+	if !ok {
+		return fmt.Errorf("only hdr quantiles can be restored from json")
+	}
+	var enc string
+	if e := json.Unmarshal(raw, &enc); e != nil {
+		return e
+	}
+	h, e := DecodeHdrHistogram(enc)
+	if e != nil {
+		return e
+	}
+	*q = Quantile{h: h, n: h.TotalCount(), min: float64(h.Min()), max: float64(h.Max())}
+	//the exact edges are in the json too
+	json.Unmarshal(m["min"], &q.min)
+	json.Unmarshal(m["max"], &q.max)
+	return nil
+}
+
 func (s *ReqStats) update(atmpt ReqAtmpt, now time.Time, cfg Config) {
 	s.ReqAtmpts++
+	if s.bucket != nil {
+		s.bucket.add(atmpt)
+	}
 	s.ElpsdNs = now.Sub(s.Start)
 
 	s.MeanReqAtmptsPSec = int64(math.Floor(float64(s.ReqAtmpts) / s.ElpsdNs.Seconds()))
@@ -198,16 +404,175 @@ func (s *ReqStats) update(atmpt ReqAtmpt, now time.Time, cfg Config) {
 	s.ElpsdAtmptLatencyNs.Add(float64(atmpt.ElpsdNs))
 	s.ElpsdAtmptLatencyNsQuantiles.Add(float64(atmpt.ElpsdNs.Nanoseconds()), 1)
 
-	if atmpt.ResCode == cfg.Res.Code {
+	//measured from when we intended to send, this includes any time spent waiting behind a slow response.
+	cns := atmpt.ElpsdCorrectedNs
+	if cns < atmpt.ElpsdNs {
+		cns = atmpt.ElpsdNs
+	}
+	s.ElpsdAtmptCorrectedLatencyNsQuantiles.Add(float64(cns.Nanoseconds()), 1)
+
+	//dns, connect and tls only happen on new connections, reused ones would skew them towards zero.
+	if atmpt.ConnReused {
+		s.SumConnReused++
+	}
+	s.PctConnReused = 100 * (float32(s.SumConnReused) / float32(s.ReqAtmpts))
+	if atmpt.DNSNs > 0 {
+		s.ElpsdDNSNsQuantiles.Add(float64(atmpt.DNSNs.Nanoseconds()), 1)
+	}
+	if atmpt.ConnectNs > 0 {
+		s.ElpsdConnectNsQuantiles.Add(float64(atmpt.ConnectNs.Nanoseconds()), 1)
+	}
+	if atmpt.TLSNs > 0 {
+		s.ElpsdTLSNsQuantiles.Add(float64(atmpt.TLSNs.Nanoseconds()), 1)
+	}
+	if atmpt.ResCode > 0 {
+		s.ElpsdTTFBNsQuantiles.Add(float64(atmpt.TTFBNs.Nanoseconds()), 1)
+		s.ElpsdDownloadNsQuantiles.Add(float64(atmpt.DownloadNs.Nanoseconds()), 1)
+	}
+
+	if cfg.Res.matchesCode(atmpt.ResCode) {
 		s.SumMatchingResponseCodes++
 	}
 	s.PctMatchingResponseCodes = 100 * (float32(s.SumMatchingResponseCodes) / float32(s.ReqAtmpts))
 
+	//an attempt counts once as failed, each assertion it failed counts under its own name.
+	if len(atmpt.AssertFails) > 0 {
+		s.SumAssertionFailures++
+		for _, f := range atmpt.AssertFails {
+			s.AssertionFailTypes[f]++
+		}
+	}
+	s.PctAssertionFailures = 100 * (float32(s.SumAssertionFailures) / float32(s.ReqAtmpts))
+
 	if atmpt.ResErr != "" {
 		s.SumErrors++
 		s.ErrorTypes[atmpt.ResErr]++
 	}
 	s.PctErrors = 100 * (float32(s.SumErrors) / float32(s.ReqAtmpts))
+
+	//dropped attempts are counted by the rate scheduler, they never reach this loop
+	d := atomic.LoadInt64(&s.SumDroppedReqAtmpts)
+	s.PctDroppedReqAtmpts = 100 * (float32(d) / float32(s.ReqAtmpts+d))
+
+	if atmpt.Journey != nil {
+		s.updateJourney(*atmpt.Journey)
+	}
+
+	if rs, ok := s.Reqs[atmpt.ReqName]; ok {
+		//journeys only count in aggregate
+		sa := atmpt
+		sa.Journey = nil
+		rs.update(sa, now, cfg)
+	}
+}
+
+func (s *ReqStats) updateJourney(j JourneyAtmpt) {
+	s.JourneyAtmpts++
+	s.ElpsdJourneyLatencyNsQuantiles.Add(float64(j.ElpsdNs.Nanoseconds()), 1)
+	if j.Success {
+		s.SumJourneySuccess++
+	} else {
+		if s.JourneyErrorTypes == nil {
+			s.JourneyErrorTypes = make(map[string]int)
+		}
+		s.JourneyErrorTypes[j.Err]++
+	}
+	s.PctJourneySuccess = 100 * (float32(s.SumJourneySuccess) / float32(s.JourneyAtmpts))
+}
+
+func (s *ReqStats) merge(o *ReqStats) error {
+	s.ReqAtmpts += o.ReqAtmpts
+	s.SumDroppedReqAtmpts += o.SumDroppedReqAtmpts
+	s.SumBytesRead += o.SumBytesRead
+	s.SumBytesWritten += o.SumBytesWritten
+	//runs overlap, so the peak the target saw is at most what all of them peaked at together.
+	s.MaxReqAtmptsPSec += o.MaxReqAtmptsPSec
+	s.MaxBytesReadPSec += o.MaxBytesReadPSec
+	s.MaxBytesWrittenPSec += o.MaxBytesWrittenPSec
+	if s.Start.IsZero() || (!o.Start.IsZero() && o.Start.Before(s.Start)) {
+		s.Start = o.Start
+	}
+	if o.ElpsdNs > s.ElpsdNs {
+		s.ElpsdNs = o.ElpsdNs
+	}
+
+	qs := [][2]*Quantile{
+		{s.ElpsdAtmptLatencyNsQuantiles, o.ElpsdAtmptLatencyNsQuantiles},
+		{s.ElpsdAtmptCorrectedLatencyNsQuantiles, o.ElpsdAtmptCorrectedLatencyNsQuantiles},
+		{s.ElpsdDNSNsQuantiles, o.ElpsdDNSNsQuantiles},
+		{s.ElpsdConnectNsQuantiles, o.ElpsdConnectNsQuantiles},
+		{s.ElpsdTLSNsQuantiles, o.ElpsdTLSNsQuantiles},
+		{s.ElpsdTTFBNsQuantiles, o.ElpsdTTFBNsQuantiles},
+		{s.ElpsdDownloadNsQuantiles, o.ElpsdDownloadNsQuantiles},
+		{s.ElpsdJourneyLatencyNsQuantiles, o.ElpsdJourneyLatencyNsQuantiles},
+	}
+	for _, q := range qs {
+		if q[1] == nil {
+			continue
+		}
+		if e := q[0].Merge(q[1]); e != nil {
+			return e
+		}
+	}
+	if o.ElpsdAtmptLatencyNs != nil {
+		s.ElpsdAtmptLatencyNs.Merge(o.ElpsdAtmptLatencyNs)
+	}
+
+	s.SumConnReused += o.SumConnReused
+	s.SumMatchingResponseCodes += o.SumMatchingResponseCodes
+	s.SumAssertionFailures += o.SumAssertionFailures
+	s.SumErrors += o.SumErrors
+	s.JourneyAtmpts += o.JourneyAtmpts
+	s.SumJourneySuccess += o.SumJourneySuccess
+	for k, v := range o.AssertionFailTypes {
+		s.AssertionFailTypes[k] += v
+	}
+	for k, v := range o.ErrorTypes {
+		s.ErrorTypes[k] += v
+	}
+	for k, v := range o.JourneyErrorTypes {
+		if s.JourneyErrorTypes == nil {
+			s.JourneyErrorTypes = make(map[string]int)
+		}
+		s.JourneyErrorTypes[k] += v
+	}
+	if s.Sample.Server == emptySampleMsg {
+		s.Sample = o.Sample
+	}
+
+	s.mergeSeries(o.Series)
+
+	for k, ors := range o.Reqs {
+		rs, ok := s.Reqs[k]
+		if !ok {
+			continue
+		}
+		if e := rs.merge(ors); e != nil {
+			return e
+		}
+	}
+	s.summarize()
+	return nil
+}
+
+func (s *ReqStats) summarize() {
+	if secs := s.ElpsdNs.Seconds(); secs > 0 {
+		s.MeanReqAtmptsPSec = int64(math.Floor(float64(s.ReqAtmpts) / secs))
+		s.MeanBytesReadPSec = int64(math.Floor(float64(s.SumBytesRead) / secs))
+		s.MeanBytesWrittenPSec = int64(math.Floor(float64(s.SumBytesWritten) / secs))
+	}
+	pct := func(v int64, of int64) float32 {
+		if of == 0 {
+			return 0
+		}
+		return 100 * (float32(v) / float32(of))
+	}
+	s.PctConnReused = pct(int64(s.SumConnReused), s.ReqAtmpts)
+	s.PctMatchingResponseCodes = pct(int64(s.SumMatchingResponseCodes), s.ReqAtmpts)
+	s.PctAssertionFailures = pct(int64(s.SumAssertionFailures), s.ReqAtmpts)
+	s.PctErrors = pct(int64(s.SumErrors), s.ReqAtmpts)
+	s.PctDroppedReqAtmpts = pct(s.SumDroppedReqAtmpts, s.ReqAtmpts+s.SumDroppedReqAtmpts)
+	s.PctJourneySuccess = pct(int64(s.SumJourneySuccess), s.JourneyAtmpts)
 }
 
 type OSOpenConns struct {
@@ -230,14 +595,20 @@ func (oss *OSOpenConns) updateOpenConns(cfg Config) {
 		_ = e
 	} else {
 		d := 0
+		reqs := cfg.reqs()
 		for c := cs.Next(); c != nil; c = cs.Next() {
 			// fixes bug where PID connections to other network infra are reported as false positive, see:
 			// https://github.com/simonmittag/p0d/issues/31
 			if c.PID == uint(oss.PID) {
-				for _, ip := range cfg.Req.Ips {
-					if c.RemotePort == cfg.getRemotePort() &&
-						ip.Equal(c.RemoteAddress) {
-						d++
+			Match:
+				//request templates may share a remote, count each conn once.
+				for _, r := range reqs {
+					for _, ip := range r.Ips {
+						if c.RemotePort == r.getRemotePort() &&
+							ip.Equal(c.RemoteAddress) {
+							d++
+							break Match
+						}
 					}
 				}
 			}
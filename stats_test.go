@@ -12,11 +12,14 @@ func TestUpdateStats(t *testing.T) {
 	cfg := Config{Res: Res{Code: 200}}
 
 	s := ReqStats{
-		ReqAtmpts:                    11,
-		Start:                        time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
-		ErrorTypes:                   make(map[string]int),
-		ElpsdAtmptLatencyNsQuantiles: NewQuantile(),
-		ElpsdAtmptLatencyNs:          &Welford{s: variance.New()},
+		ReqAtmpts:                             11,
+		Start:                                 time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		ErrorTypes:                            make(map[string]int),
+		ElpsdAtmptLatencyNsQuantiles:          NewQuantile(),
+		ElpsdAtmptCorrectedLatencyNsQuantiles: NewQuantile(),
+		ElpsdAtmptLatencyNs:                   &Welford{s: variance.New()},
+		ElpsdTTFBNsQuantiles:                  NewQuantile(),
+		ElpsdDownloadNsQuantiles:              NewQuantile(),
 	}
 
 	g := ReqAtmpt{
@@ -144,6 +147,89 @@ func TestUpdateStats(t *testing.T) {
 	}
 }
 
+func TestUpdateStatsCorrectedLatency(t *testing.T) {
+	cfg := Config{Res: Res{Code: 200}}
+
+	s := ReqStats{
+		Start:                                 time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		ErrorTypes:                            make(map[string]int),
+		ElpsdAtmptLatencyNsQuantiles:          NewQuantile(),
+		ElpsdAtmptCorrectedLatencyNsQuantiles: NewQuantile(),
+		ElpsdAtmptLatencyNs:                   NewWelford(),
+		ElpsdTTFBNsQuantiles:                  NewQuantile(),
+		ElpsdDownloadNsQuantiles:              NewQuantile(),
+	}
+
+	//this request was intended to go out 3s before it was actually sent.
+	g := ReqAtmpt{
+		Intended:         time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC),
+		Start:            time.Date(2000, 1, 1, 0, 0, 4, 0, time.UTC),
+		Stop:             time.Date(2000, 1, 1, 0, 0, 5, 0, time.UTC),
+		ElpsdNs:          time.Duration(1 * time.Second),
+		ElpsdCorrectedNs: time.Duration(4 * time.Second),
+		ResCode:          200,
+	}
+	s.update(g, g.Stop, cfg)
+
+	if time.Duration(s.ElpsdAtmptLatencyNsQuantiles.Quantile(0.5)) != time.Second {
+		t.Error("service time latency incorrect")
+	}
+	if time.Duration(s.ElpsdAtmptCorrectedLatencyNsQuantiles.Quantile(0.5)) != 4*time.Second {
+		t.Error("corrected latency incorrect")
+	}
+
+	//attempts without an intended start fall back to service time
+	s.ElpsdAtmptCorrectedLatencyNsQuantiles = NewQuantile()
+	g2 := ReqAtmpt{
+		Start:   time.Date(2000, 1, 1, 0, 0, 5, 0, time.UTC),
+		Stop:    time.Date(2000, 1, 1, 0, 0, 7, 0, time.UTC),
+		ElpsdNs: time.Duration(2 * time.Second),
+		ResCode: 200,
+	}
+	s.update(g2, g2.Stop, cfg)
+
+	if time.Duration(s.ElpsdAtmptCorrectedLatencyNsQuantiles.Quantile(0.5)) != 2*time.Second {
+		t.Error("corrected latency should fall back to service time")
+	}
+}
+
+func TestUpdateStatsPerReq(t *testing.T) {
+	cfg := Config{
+		Res:  Res{Code: 200},
+		Reqs: []Req{{Name: "get"}, {Name: "post"}},
+	}
+
+	s := NewReqStats(cfg)
+	s.setStart(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	g := ReqAtmpt{
+		Start:   time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC),
+		Stop:    time.Date(2000, 1, 1, 0, 0, 2, 0, time.UTC),
+		ElpsdNs: time.Duration(1 * time.Second),
+		ReqName: "get",
+		ResCode: 200,
+	}
+	s.update(g, g.Stop, cfg)
+	s.update(g, g.Stop, cfg)
+
+	g.ReqName = "post"
+	g.ResCode = 500
+	s.update(g, g.Stop, cfg)
+
+	if s.ReqAtmpts != 3 || s.SumMatchingResponseCodes != 2 {
+		t.Error("aggregate stats incorrect")
+	}
+	if s.Reqs["get"].ReqAtmpts != 2 || s.Reqs["get"].SumMatchingResponseCodes != 2 {
+		t.Error("get stats incorrect")
+	}
+	if s.Reqs["post"].ReqAtmpts != 1 || s.Reqs["post"].SumMatchingResponseCodes != 0 {
+		t.Error("post stats incorrect")
+	}
+	if s.Reqs["post"].MeanReqAtmptsPSec != 0 || s.Reqs["get"].ElpsdNs != 2*time.Second {
+		t.Error("per request stats should share start time")
+	}
+}
+
 func TestUpdateOSStats(t *testing.T) {
 	oss := NewOSOpenConns(1)
 	oss.updateOpenConns(Config{Exec: Exec{Concurrency: 3}})
@@ -190,3 +276,49 @@ func BenchmarkNewOSNet(b *testing.B) {
 		_ = e
 	}
 }
+
+func TestUpdateStatsPhases(t *testing.T) {
+	cfg := Config{Res: Res{Code: 200}}
+	s := NewReqStats(cfg)
+	now := time.Now()
+	s.setStart(now.Add(-time.Second))
+
+	s.update(ReqAtmpt{ResCode: 200, DNSNs: 1 * time.Millisecond, ConnectNs: 2 * time.Millisecond,
+		TLSNs: 3 * time.Millisecond, TTFBNs: 4 * time.Millisecond, DownloadNs: 5 * time.Millisecond}, now, cfg)
+	s.update(ReqAtmpt{ResCode: 200, ConnReused: true, TTFBNs: 4 * time.Millisecond,
+		DownloadNs: 5 * time.Millisecond}, now, cfg)
+
+	if time.Duration(s.ElpsdDNSNsQuantiles.Quantile(0.5)) != time.Millisecond {
+		t.Error("reused conns should not count towards dns")
+	}
+	if time.Duration(s.ElpsdConnectNsQuantiles.Quantile(0.5)) != 2*time.Millisecond {
+		t.Error("reused conns should not count towards connect")
+	}
+	if time.Duration(s.ElpsdTTFBNsQuantiles.Quantile(0.5)) != 4*time.Millisecond {
+		t.Error("ttfb incorrect")
+	}
+	if s.SumConnReused != 1 || s.PctConnReused != 50 {
+		t.Errorf("conn reused incorrect, got %v %v", s.SumConnReused, s.PctConnReused)
+	}
+}
+
+func TestRenewStats(t *testing.T) {
+	cfg := Config{Exec: Exec{Histogram: Histogram{Type: HistogramHdr, Precision: 3}}, Res: Res{Code: 200}}
+	s := NewReqStats(cfg)
+	s.update(ReqAtmpt{ResCode: 200, ElpsdNs: time.Millisecond}, time.Now(), cfg)
+
+	n := s.renew()
+	if n.ReqAtmpts != 0 || n.ElpsdAtmptLatencyNsQuantiles.n != 0 {
+		t.Error("renewed stats should be empty")
+	}
+	if n.ElpsdAtmptLatencyNsQuantiles != s.ElpsdAtmptLatencyNsQuantiles || n.ElpsdAtmptLatencyNsQuantiles.h == nil {
+		t.Error("renewed stats should keep their hdr histograms")
+	}
+	if !math.IsNaN(n.ElpsdAtmptLatencyNsQuantiles.Quantile(0.5)) {
+		t.Error("renewed histograms should be cleared")
+	}
+	n.update(ReqAtmpt{ResCode: 200, ElpsdNs: 2 * time.Millisecond}, time.Now(), cfg)
+	if v := n.ElpsdAtmptLatencyNsQuantiles.Quantile(0.5); v != float64(2*time.Millisecond) {
+		t.Errorf("renewed histograms should record again, got %v", v)
+	}
+}
@@ -0,0 +1,109 @@
+package p0d
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+const tmplOpen = "{{"
+
+var timeLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+}
+
+func (cfg *Config) tmplFuncs() template.FuncMap {
+	if cfg.seq == nil {
+		cfg.seq = new(int64)
+	}
+	seq := cfg.seq
+	return template.FuncMap{
+		"uuid": func() string {
+			return uuid.NewString()
+		},
+		"randInt": func(min int, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + rand.Intn(max-min+1)
+		},
+		"seq": func() int64 {
+			return atomic.AddInt64(seq, 1)
+		},
+		"now": func(layout ...string) string {
+			now := time.Now()
+			if len(layout) == 0 {
+				return now.Format(time.RFC3339)
+			}
+			switch layout[0] {
+			case "Unix":
+				return strconv.FormatInt(now.Unix(), 10)
+			case "UnixMilli":
+				return strconv.FormatInt(now.UnixMilli(), 10)
+			}
+			if l, ok := timeLayouts[layout[0]]; ok {
+				return now.Format(l)
+			}
+			return now.Format(layout[0])
+		},
+		"env": func(key string) string {
+			return os.Getenv(key)
+		},
+	}
+}
+
+func (cfg *Config) compileTemplates(r *Req) {
+	//we compile once here so rendering each attempt is cheap and doesn't skew timing.
+	r.tmpls = make(map[string]*template.Template)
+	funcs := cfg.tmplFuncs()
+	compile := func(s string) {
+		if !strings.Contains(s, tmplOpen) {
+			return
+		}
+		if _, ok := r.tmpls[s]; ok {
+			return
+		}
+		t, e := template.New(r.Name).Funcs(funcs).Option("missingkey=zero").Parse(s)
+		if e != nil {
+			cfg.panic(fmt.Sprintf("unable to parse template '%s': %s", s, e.Error()))
+		}
+		r.tmpls[s] = t
+	}
+
+	compile(r.Url)
+	compile(r.Body)
+	for _, h := range r.Headers {
+		for _, v := range h {
+			compile(v)
+		}
+	}
+	for _, fd := range r.FormData {
+		for _, v := range fd {
+			compile(v)
+		}
+	}
+}
+
+func (r *Req) render(s string, vars map[string]string) (string, error) {
+	t, ok := r.tmpls[s]
+	if !ok {
+		return s, nil
+	}
+	b := strings.Builder{}
+	if e := t.Execute(&b, vars); e != nil {
+		return s, e
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,122 @@
+package p0d
+
+import (
+	"github.com/google/uuid"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	os.Setenv("P0D_TEST_TOKEN", "secret")
+	defer os.Unsetenv("P0D_TEST_TOKEN")
+
+	cfg := Config{
+		Req: Req{
+			Url: "http://localhost:8080/items/{{randInt 5 7}}?seq={{seq}}",
+			Headers: []map[string]string{
+				{"Authorization": "Bearer {{env \"P0D_TEST_TOKEN\"}}"},
+				{"X-Request-Id": "{{uuid}}"},
+				{"X-Plain": "plain"},
+			},
+			Body:        "{\"at\": \"{{now `Unix`}}\", \"user\": \"{{.user}}\"}",
+			ContentType: applicationJson,
+		},
+	}
+	cfg.validate()
+	r := &cfg.Req
+
+	if len(r.tmpls) != 4 {
+		t.Errorf("should have compiled 4 templates, got %v", len(r.tmpls))
+	}
+
+	for i := 1; i <= 3; i++ {
+		u, _ := r.render(r.Url, nil)
+		n, _ := strconv.Atoi(u[len("http://localhost:8080/items/"):strings.Index(u, "?")])
+		if n < 5 || n > 7 {
+			t.Errorf("randInt out of range, got %v", n)
+		}
+		if !strings.HasSuffix(u, "?seq="+strconv.Itoa(i)) {
+			t.Errorf("seq should count up, got %v", u)
+		}
+	}
+
+	if a, _ := r.render(r.Headers[0]["Authorization"], nil); a != "Bearer secret" {
+		t.Errorf("env not rendered, got %v", a)
+	}
+	id, _ := r.render(r.Headers[1]["X-Request-Id"], nil)
+	if _, e := uuid.Parse(id); e != nil {
+		t.Errorf("uuid not rendered, got %v", id)
+	}
+	if id2, _ := r.render(r.Headers[1]["X-Request-Id"], nil); id == id2 {
+		t.Error("uuid should be fresh each render")
+	}
+	if pl, _ := r.render("plain", nil); pl != "plain" {
+		t.Error("plain values should pass through")
+	}
+
+	b, _ := r.render(r.Body, map[string]string{"user": "p0d"})
+	if !strings.Contains(b, "\"user\": \"p0d\"") {
+		t.Errorf("vars not rendered, got %v", b)
+	}
+	if !strings.Contains(b, strconv.FormatInt(time.Now().Unix(), 10)[:8]) {
+		t.Errorf("now not rendered, got %v", b)
+	}
+	if b2, _ := r.render(r.Body, nil); !strings.Contains(b2, "\"user\": \"\"") {
+		t.Errorf("missing vars should render empty, got %v", b2)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	cfg := Config{
+		Req: Req{
+			Url: "http://localhost:8080/items/{{randInt \"a\" 7}}",
+		},
+	}
+	cfg.validate()
+	p := NewP0d(cfg, 0, "", 10, nil)
+
+	if _, e := p.scaffoldHttpReqFor(&p.Config.Req, nil); e == nil {
+		t.Error("failed render should return an error")
+	}
+	ra := p.doReqAtmptFor(0, time.Time{}, &p.Config.Req, nil, nil)
+	if ra.ResErr == N || ra.ResCode != 0 {
+		t.Errorf("failed render should be an error on the attempt without sending, got %v", ra.ResErr)
+	}
+}
+
+func TestScaffoldHttpReqKeepsSeq(t *testing.T) {
+	cfg := Config{
+		Req: Req{
+			Url: "http://localhost:8080/items?seq={{seq}}",
+		},
+	}
+	cfg.validate()
+	p := P0d{Config: cfg}
+
+	p.scaffoldHttpReq()
+	req, _ := p.scaffoldHttpReqFor(&p.Config.Req, nil)
+	if req.URL.Query().Get("seq") != "1" {
+		t.Errorf("probe request should not advance seq, got %v", req.URL.String())
+	}
+}
+
+func TestRenderNow(t *testing.T) {
+	cfg := Config{}
+	now := cfg.tmplFuncs()["now"].(func(...string) string)
+
+	if _, e := time.Parse(time.RFC3339, now()); e != nil {
+		t.Error("now should default to RFC3339")
+	}
+	if _, e := time.Parse(time.RFC1123, now("RFC1123")); e != nil {
+		t.Error("now should know layout names")
+	}
+	if _, e := time.Parse("2006-01-02", now("2006-01-02")); e != nil {
+		t.Error("now should take go layouts")
+	}
+	if _, e := strconv.ParseInt(now("UnixMilli"), 10, 64); e != nil {
+		t.Error("now should render unix millis")
+	}
+}
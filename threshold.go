@@ -0,0 +1,244 @@
+package p0d
+
+import (
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExitOK               = 0
+	ExitErrors           = 1
+	ExitConfigError      = 2
+	ExitThresholdsFailed = 3
+	ExitAborted          = 4
+	ExitRegression       = 5
+	ExitInterrupted      = 130
+)
+
+type thresholdKind int
+
+const (
+	latencyKind thresholdKind = iota
+	pctKind
+	rateKind
+)
+
+type thresholdMetric struct {
+	kind  thresholdKind
+	value func(s *ReqStats) float64
+}
+
+func latencyMetric(q float64, corrected bool) thresholdMetric {
+	return thresholdMetric{kind: latencyKind, value: func(s *ReqStats) float64 {
+		qs := s.ElpsdAtmptLatencyNsQuantiles
+		if corrected {
+			qs = s.ElpsdAtmptCorrectedLatencyNsQuantiles
+		}
+		v := qs.Quantile(q)
+		if math.IsNaN(v) {
+			return 0
+		}
+		return v
+	}}
+}
+
+var thresholdMetrics = map[string]thresholdMetric{
+	"p50":             latencyMetric(0.5, false),
+	"p90":             latencyMetric(0.9, false),
+	"p95":             latencyMetric(0.95, false),
+	"p99":             latencyMetric(0.99, false),
+	"p99.9":           latencyMetric(0.999, false),
+	"p99.99":          latencyMetric(0.9999, false),
+	"max":             latencyMetric(1, false),
+	"correctedP50":    latencyMetric(0.5, true),
+	"correctedP90":    latencyMetric(0.9, true),
+	"correctedP95":    latencyMetric(0.95, true),
+	"correctedP99":    latencyMetric(0.99, true),
+	"correctedP99.9":  latencyMetric(0.999, true),
+	"correctedP99.99": latencyMetric(0.9999, true),
+	"correctedMax":    latencyMetric(1, true),
+	"errors":          {kind: pctKind, value: func(s *ReqStats) float64 { return float64(s.PctErrors) }},
+	"matchingCodes":   {kind: pctKind, value: func(s *ReqStats) float64 { return float64(s.PctMatchingResponseCodes) }},
+	"failedAssertions": {kind: pctKind, value: func(s *ReqStats) float64 {
+		return float64(s.PctAssertionFailures)
+	}},
+	"dropped":        {kind: pctKind, value: func(s *ReqStats) float64 { return float64(s.PctDroppedReqAtmpts) }},
+	"journeySuccess": {kind: pctKind, value: func(s *ReqStats) float64 { return float64(s.PctJourneySuccess) }},
+	"meanRps":        {kind: rateKind, value: func(s *ReqStats) float64 { return float64(s.MeanReqAtmptsPSec) }},
+	"maxRps":         {kind: rateKind, value: func(s *ReqStats) float64 { return float64(s.MaxReqAtmptsPSec) }},
+}
+
+var thresholdUnits = map[string]float64{
+	"us": float64(time.Microsecond),
+	"μs": float64(time.Microsecond),
+	"ms": float64(time.Millisecond),
+	"s":  float64(time.Second),
+}
+
+var thresholdRegex = regexp.MustCompile(`^\s*([\w.]+)\s*(<=|>=|<|>)\s*([0-9]*\.?[0-9]+)\s*(us|μs|ms|s|%)?\s*$`)
+
+type Threshold struct {
+	Metric string
+	Op     string
+	Value  float64
+
+	raw    string
+	metric thresholdMetric
+}
+
+type ThresholdResult struct {
+	Threshold string
+	Actual    string
+	Pass      bool
+}
+
+type Verdict struct {
+	Pass       bool
+	Thresholds []ThresholdResult
+}
+
+func parseThreshold(s string) (Threshold, error) {
+	m := thresholdRegex.FindStringSubmatch(s)
+	if m == nil {
+		return Threshold{}, fmt.Errorf("unable to parse threshold '%s', want i.e. 'p99 < 250ms'", s)
+	}
+	t := Threshold{Metric: m[1], Op: m[2], raw: strings.TrimSpace(s)}
+	metric, ok := thresholdMetrics[t.Metric]
+	if !ok {
+		names := make([]string, 0, len(thresholdMetrics))
+		for k := range thresholdMetrics {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return Threshold{}, fmt.Errorf("unknown threshold metric '%s', use one of %s", t.Metric,
+			strings.Join(names, ", "))
+	}
+	t.metric = metric
+	t.Value, _ = strconv.ParseFloat(m[3], 64)
+
+	unit := m[4]
+	switch metric.kind {
+	case latencyKind:
+		//latencies are kept in ns
+		f, ok := thresholdUnits[unit]
+		if !ok {
+			return Threshold{}, fmt.Errorf("threshold '%s' needs a time unit, one of us, ms, s", s)
+		}
+		t.Value *= f
+	case pctKind:
+		if unit != "" && unit != "%" {
+			return Threshold{}, fmt.Errorf("threshold '%s' is a percentage", s)
+		}
+	case rateKind:
+		if unit != "" {
+			return Threshold{}, fmt.Errorf("threshold '%s' is per second, it takes no unit", s)
+		}
+	}
+	return t, nil
+}
+
+func (cfg *Config) validateThresholds() {
+	cfg.thresholds = make([]Threshold, 0, len(cfg.Thresholds))
+	for _, s := range cfg.Thresholds {
+		t, e := parseThreshold(s)
+		if e != nil {
+			cfg.panic(e.Error())
+		}
+		cfg.thresholds = append(cfg.thresholds, t)
+	}
+}
+
+func (t Threshold) actual(s *ReqStats) float64 {
+	return t.metric.value(s)
+}
+
+func (t Threshold) holds(v float64) bool {
+	switch t.Op {
+	case "<":
+		return v < t.Value
+	case "<=":
+		return v <= t.Value
+	case ">":
+		return v > t.Value
+	default:
+		return v >= t.Value
+	}
+}
+
+func (t Threshold) format(v float64) string {
+	switch t.metric.kind {
+	case latencyKind:
+		c := time.Duration(int64(v))
+		if c.Milliseconds() == 0 {
+			return FGroup(c.Microseconds()) + "μs"
+		}
+		return FGroup(c.Milliseconds()) + "ms"
+	case pctKind:
+		return fmt.Sprintf("%.2f%%", v)
+	default:
+		return FGroup(int64(v)) + perSecondMsg
+	}
+}
+
+func (p *P0d) evalThresholds() {
+	if len(p.Config.thresholds) == 0 {
+		return
+	}
+	v := &Verdict{Pass: true}
+	for _, t := range p.Config.thresholds {
+		a := t.actual(p.ReqStats)
+		r := ThresholdResult{Threshold: t.raw, Actual: t.format(a), Pass: t.holds(a)}
+		if !r.Pass {
+			v.Pass = false
+		}
+		v.Thresholds = append(v.Thresholds, r)
+	}
+	p.Verdict = v
+}
+
+func (p *P0d) logVerdict() {
+	if p.AbortReason != N {
+		logv(Red(fmt.Sprintf("aborted: %s", p.AbortReason)))
+	}
+	if p.Verdict == nil {
+		return
+	}
+	if p.Verdict.Pass {
+		logv(Cyan("thresholds: passed"))
+	} else {
+		logv(Red("thresholds: failed"))
+	}
+	for _, r := range p.Verdict.Thresholds {
+		if r.Pass {
+			logv(Cyan(fmt.Sprintf("  - threshold [%s]: %s passed", r.Threshold, r.Actual)))
+		} else {
+			logv(Red(fmt.Sprintf("  - threshold [%s]: %s failed", r.Threshold, r.Actual)))
+		}
+	}
+}
+
+func (p *P0d) ExitCode() int {
+	if p.Interrupted {
+		return ExitInterrupted
+	}
+	if p.AbortReason != N {
+		return ExitAborted
+	}
+	//with thresholds, they decide. without, any transport error fails the run.
+	if p.Verdict != nil {
+		if !p.Verdict.Pass {
+			return ExitThresholdsFailed
+		}
+		return ExitOK
+	}
+	if p.ReqStats.SumErrors > 0 {
+		return ExitErrors
+	}
+	return ExitOK
+}
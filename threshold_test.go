@@ -0,0 +1,125 @@
+package p0d
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type thresholdTest struct {
+	n      string
+	s      string
+	metric string
+	op     string
+	value  float64
+	err    bool
+}
+
+func TestParseThreshold(t *testing.T) {
+	tests := []thresholdTest{
+		{n: "latency ms", s: "p99 < 250ms", metric: "p99", op: "<", value: float64(250 * time.Millisecond)},
+		{n: "latency p99.9", s: "p99.9 <= 1s", metric: "p99.9", op: "<=", value: float64(time.Second)},
+		{n: "corrected p99.99", s: "correctedP99.99 < 2s", metric: "correctedP99.99", op: "<", value: float64(2 * time.Second)},
+		{n: "latency s", s: "correctedP95<=1.5s", metric: "correctedP95", op: "<=", value: float64(1500 * time.Millisecond)},
+		{n: "pct", s: "errors < 0.5%", metric: "errors", op: "<", value: 0.5},
+		{n: "pct no unit", s: "matchingCodes >= 99.9", metric: "matchingCodes", op: ">=", value: 99.9},
+		{n: "rate", s: " meanRps > 1000 ", metric: "meanRps", op: ">", value: 1000},
+		{n: "latency without unit", s: "p99 < 250", err: true},
+		{n: "rate with unit", s: "meanRps > 1000ms", err: true},
+		{n: "unknown metric", s: "p42 < 1ms", err: true},
+		{n: "bad op", s: "p99 == 1ms", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			th, e := parseThreshold(tt.s)
+			if tt.err {
+				if e == nil {
+					t.Error("should not parse")
+				}
+				return
+			}
+			if e != nil || th.Metric != tt.metric || th.Op != tt.op || th.Value != tt.value {
+				t.Errorf("want %v %v %v got %v %v %v %v", tt.metric, tt.op, tt.value, th.Metric, th.Op, th.Value, e)
+			}
+		})
+	}
+}
+
+func TestThresholdsConfigValidate(t *testing.T) {
+	cfg := loadConfigFromFile("./examples/config_thresholds.yml")
+	cfg.validate()
+	if len(cfg.thresholds) != 4 || cfg.thresholds[3].Metric != "meanRps" {
+		t.Error("thresholds incorrectly parsed")
+	}
+}
+
+func TestEvalThresholds(t *testing.T) {
+	cfg := Config{Res: Res{Code: 200}, Thresholds: []string{"p99 < 250ms", "errors < 10%", "meanRps > 1"}}
+	cfg.validateThresholds()
+
+	p := P0d{Config: cfg, ReqStats: NewReqStats(cfg)}
+	now := time.Now()
+	p.ReqStats.setStart(now.Add(-time.Second))
+	for i := 0; i < 10; i++ {
+		p.ReqStats.update(ReqAtmpt{ResCode: 200, ElpsdNs: time.Millisecond * 10}, now, cfg)
+	}
+
+	p.evalThresholds()
+	if p.Verdict == nil || !p.Verdict.Pass || len(p.Verdict.Thresholds) != 3 {
+		t.Errorf("thresholds should pass, got %v", p.Verdict)
+	}
+	if p.ExitCode() != ExitOK {
+		t.Errorf("want exit code %v got %v", ExitOK, p.ExitCode())
+	}
+
+	p.ReqStats.update(ReqAtmpt{ResCode: 0, ResErr: "timeout", ElpsdNs: time.Second}, now, cfg)
+	p.ReqStats.update(ReqAtmpt{ResCode: 0, ResErr: "timeout", ElpsdNs: time.Second}, now, cfg)
+	p.evalThresholds()
+	if p.Verdict.Pass || p.Verdict.Thresholds[0].Pass || p.Verdict.Thresholds[1].Pass || !p.Verdict.Thresholds[2].Pass {
+		t.Errorf("latency and error thresholds should fail, got %v", p.Verdict)
+	}
+	if p.ExitCode() != ExitThresholdsFailed {
+		t.Errorf("want exit code %v got %v", ExitThresholdsFailed, p.ExitCode())
+	}
+
+	p.Interrupted = true
+	if p.ExitCode() != ExitInterrupted {
+		t.Errorf("want exit code %v got %v", ExitInterrupted, p.ExitCode())
+	}
+}
+
+func TestExitCodeWithoutThresholds(t *testing.T) {
+	p := P0d{ReqStats: NewReqStats(Config{})}
+	if p.ExitCode() != ExitOK {
+		t.Error("should exit ok")
+	}
+	p.ReqStats.SumErrors = 1
+	if p.ExitCode() != ExitErrors {
+		t.Error("transport errors should fail without thresholds")
+	}
+}
+
+func TestRaceStoppedEarlyGivesVerdict(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p, _ := NewP0dFromConfig(Config{
+		Req:        Req{Url: svr.URL},
+		Exec:       Exec{DurationSeconds: 10, Concurrency: 2, SkipInetTest: true},
+		Thresholds: []string{"errors < 1%"},
+	}, "")
+	time.AfterFunc(time.Second*2, p.stopRun)
+	res, _ := p.Race(context.Background())
+
+	if res.Interrupted || res.Verdict == nil || !res.Verdict.Pass || res.ExitCode != ExitOK {
+		t.Errorf("stopped run should pass its thresholds, got exit code %d", res.ExitCode)
+	}
+	if res.Time.Stop.Sub(res.Time.Start) > time.Second*6 {
+		t.Error("stopped run should end early")
+	}
+}
@@ -0,0 +1,118 @@
+package p0d
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type phaseTrace struct {
+	lock         sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func (t *phaseTrace) clientTrace() *httptrace.ClientTrace {
+	//dialers may race several connects, keep the first start and the last successful finish.
+	at := func(f *time.Time) {
+		t.lock.Lock()
+		if f.IsZero() {
+			*f = time.Now()
+		}
+		t.lock.Unlock()
+	}
+	done := func(f *time.Time, e error) {
+		if e != nil {
+			return
+		}
+		t.lock.Lock()
+		*f = time.Now()
+		t.lock.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { at(&t.dnsStart) },
+		DNSDone: func(i httptrace.DNSDoneInfo) {
+			done(&t.dnsDone, i.Err)
+		},
+		ConnectStart: func(string, string) { at(&t.connectStart) },
+		ConnectDone: func(_, _ string, e error) {
+			done(&t.connectDone, e)
+		},
+		TLSHandshakeStart: func() { at(&t.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, e error) {
+			done(&t.tlsDone, e)
+		},
+		GotConn: func(i httptrace.GotConnInfo) {
+			t.lock.Lock()
+			t.reused = i.Reused
+			t.lock.Unlock()
+		},
+		WroteRequest: func(i httptrace.WroteRequestInfo) {
+			done(&t.wroteRequest, i.Err)
+		},
+		GotFirstResponseByte: func() { at(&t.firstByte) },
+	}
+}
+
+func phase(start time.Time, stop time.Time) time.Duration {
+	if start.IsZero() || stop.IsZero() || stop.Before(start) {
+		return 0
+	}
+	return stop.Sub(start)
+}
+
+func (t *phaseTrace) record(ra *ReqAtmpt) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ra.DNSNs = phase(t.dnsStart, t.dnsDone)
+	ra.ConnectNs = phase(t.connectStart, t.connectDone)
+	ra.TLSNs = phase(t.tlsStart, t.tlsDone)
+	//ttfb is the wait after sending, that's the server's think time plus the network.
+	ra.TTFBNs = phase(t.wroteRequest, t.firstByte)
+	ra.DownloadNs = phase(t.firstByte, ra.Stop)
+	ra.ConnReused = t.reused
+}
+
+func dialTLSTraced(ctx context.Context, nd *net.Dialer, network string, addr string, cfg *tls.Config) (net.Conn, error) {
+	//we dial tls ourselves, so the transport never reports the handshake. do it here.
+	//the dial timeout includes the handshake, both share one deadline.
+	if nd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, time.Now().Add(nd.Timeout))
+		defer cancel()
+	}
+	raw, e := nd.DialContext(ctx, network, addr)
+	if e != nil {
+		return nil, e
+	}
+	c := cfg.Clone()
+	if c.ServerName == "" {
+		host, _, _ := net.SplitHostPort(addr)
+		c.ServerName = host
+	}
+	conn := tls.Client(raw, c)
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	e = conn.HandshakeContext(ctx)
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(conn.ConnectionState(), e)
+	}
+	if e != nil {
+		raw.Close()
+		return nil, e
+	}
+	return conn, nil
+}
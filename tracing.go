@@ -0,0 +1,313 @@
+package p0d
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const traceparent = "traceparent"
+const spanBatchSize = 512
+const spanBuffer = 4096
+const spanFlushInterval = time.Second
+
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanStatusOk     = 1
+	spanStatusError  = 2
+)
+
+type Tracing struct {
+	Endpoint    string
+	Sampling    float64
+	ServiceName string
+	Headers     map[string]string
+}
+
+func (cfg *Config) validateTracing() {
+	tr := &cfg.Exec.Tracing
+	if tr.Sampling < 0 || tr.Sampling > 1 {
+		cfg.panic(fmt.Sprintf("tracing sampling must be between 0 and 1, yours: %v", tr.Sampling))
+	}
+	if tr.Endpoint != N {
+		if u, e := url.Parse(tr.Endpoint); e != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			cfg.panic(fmt.Sprintf("tracing endpoint must be an http or https OTLP url, yours: %s", tr.Endpoint))
+		}
+		//exporting spans implies tracing, trace everything unless told otherwise.
+		if tr.Sampling == 0 {
+			tr.Sampling = 1
+		}
+	}
+	if tr.ServiceName == N {
+		tr.ServiceName = "p0d"
+	}
+}
+
+func (cfg *Config) isTracing() bool {
+	return cfg.Exec.Tracing.Sampling > 0
+}
+
+func newTraceId() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newSpanId() string {
+	b := make([]byte, 8)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (p *P0d) traceReq(req *http.Request, ra *ReqAtmpt) {
+	//attempts logged to -O are always traced, so every logged request can be found in the collector too.
+	if !p.Config.isTracing() || (!ra.logged && rand.Float64() >= p.Config.Exec.Tracing.Sampling) {
+		return
+	}
+	ra.TraceID = newTraceId()
+	ra.SpanID = newSpanId()
+	//w3c trace context, version 00 and sampled.
+	req.Header.Set(traceparent, fmt.Sprintf("00-%s-%s-01", ra.TraceID, ra.SpanID))
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string     `json:"traceId"`
+	SpanId            string     `json:"spanId"`
+	ParentSpanId      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+}
+
+func strAttr(k string, v string) otlpAttr {
+	return otlpAttr{Key: k, Value: otlpValue{StringValue: &v}}
+}
+
+func intAttr(k string, v int64) otlpAttr {
+	s := strconv.FormatInt(v, 10)
+	return otlpAttr{Key: k, Value: otlpValue{IntValue: &s}}
+}
+
+func boolAttr(k string, v bool) otlpAttr {
+	return otlpAttr{Key: k, Value: otlpValue{BoolValue: &v}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (p *P0d) reqSpans(req *http.Request, ra ReqAtmpt, pt *phaseTrace) []otlpSpan {
+	s := otlpSpan{
+		TraceId:           ra.TraceID,
+		SpanId:            ra.SpanID,
+		Name:              "HTTP " + req.Method,
+		Kind:              spanKindClient,
+		StartTimeUnixNano: unixNano(ra.Start),
+		EndTimeUnixNano:   unixNano(ra.Stop),
+		Attributes: []otlpAttr{
+			strAttr("http.request.method", req.Method),
+			strAttr("url.full", req.URL.String()),
+			intAttr("http.response.status_code", int64(ra.ResCode)),
+			boolAttr("p0d.conn.reused", ra.ConnReused),
+			strAttr("p0d.run.id", p.ID),
+		},
+		Status: otlpStatus{Code: spanStatusOk},
+	}
+	if ra.ReqName != N {
+		s.Attributes = append(s.Attributes, strAttr("p0d.req.name", ra.ReqName))
+	}
+	if ra.ResErr != N {
+		s.Status = otlpStatus{Code: spanStatusError, Message: ra.ResErr}
+	} else if !p.Config.Res.matchesCode(ra.ResCode) {
+		s.Status = otlpStatus{Code: spanStatusError, Message: fmt.Sprintf("HTTP %d", ra.ResCode)}
+	}
+	spans := []otlpSpan{s}
+
+	//each httptrace phase becomes a child span so collectors show where the time went.
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	phases := []struct {
+		name        string
+		start, stop time.Time
+	}{
+		{"dns", pt.dnsStart, pt.dnsDone},
+		{"connect", pt.connectStart, pt.connectDone},
+		{"tls", pt.tlsStart, pt.tlsDone},
+		{"ttfb", pt.wroteRequest, pt.firstByte},
+		{"download", pt.firstByte, ra.Stop},
+	}
+	for _, ph := range phases {
+		if phase(ph.start, ph.stop) == 0 {
+			continue
+		}
+		spans = append(spans, otlpSpan{
+			TraceId:           ra.TraceID,
+			SpanId:            newSpanId(),
+			ParentSpanId:      ra.SpanID,
+			Name:              ph.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(ph.start),
+			EndTimeUnixNano:   unixNano(ph.stop),
+		})
+	}
+	return spans
+}
+
+type spanExporter struct {
+	cfg     Tracing
+	spans   chan []otlpSpan
+	stop    chan struct{}
+	done    chan struct{}
+	client  *http.Client
+	dropped int64
+}
+
+func (p *P0d) initSpanExporter() {
+	if p.Config.Exec.Tracing.Endpoint == N {
+		return
+	}
+	p.spanExporter = &spanExporter{
+		cfg:    p.Config.Exec.Tracing,
+		spans:  make(chan []otlpSpan, spanBuffer),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	go p.spanExporter.run()
+	p.logf("exporting %s of trace spans to %s",
+		Yellow(fmt.Sprintf("%.2f%%", p.Config.Exec.Tracing.Sampling*100)),
+		Yellow(p.Config.Exec.Tracing.Endpoint))
+}
+
+func (p *P0d) exportSpans(req *http.Request, ra ReqAtmpt, pt *phaseTrace) {
+	if p.spanExporter == nil || ra.TraceID == N {
+		return
+	}
+	//like pushing metrics, a slow collector must never slow down requests.
+	spans := p.reqSpans(req, ra, pt)
+	select {
+	case p.spanExporter.spans <- spans:
+	default:
+		atomic.AddInt64(&p.spanExporter.dropped, int64(len(spans)))
+	}
+}
+
+func (p *P0d) stopSpanExporter() {
+	if p.spanExporter == nil {
+		return
+	}
+	//a late request may still export its spans, so we never close the spans channel.
+	close(p.spanExporter.stop)
+	select {
+	case <-p.spanExporter.done:
+	case <-time.After(pushTimeout):
+	}
+}
+
+func (se *spanExporter) run() {
+	defer close(se.done)
+	t := time.NewTicker(spanFlushInterval)
+	defer t.Stop()
+
+	batch := make([]otlpSpan, 0, spanBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if e := se.send(batch); e != nil {
+			atomic.AddInt64(&se.dropped, int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+Export:
+	for {
+		select {
+		case s := <-se.spans:
+			batch = append(batch, s...)
+			if len(batch) >= spanBatchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		case <-se.stop:
+			break Export
+		}
+	}
+
+	//take what's already waiting, then flush one last time.
+Drain:
+	for {
+		select {
+		case s := <-se.spans:
+			batch = append(batch, s...)
+			if len(batch) >= spanBatchSize {
+				flush()
+			}
+		default:
+			break Drain
+		}
+	}
+	flush()
+}
+
+func (se *spanExporter) send(spans []otlpSpan) error {
+	body := map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": []otlpAttr{strAttr("service.name", se.cfg.ServiceName)},
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]string{"name": "p0d", "version": Version},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+	j, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", se.cfg.Endpoint, bytes.NewReader(j))
+	req.Header.Set(ct, applicationJson)
+	for k, v := range se.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	res, e := se.client.Do(req)
+	if e != nil {
+		return e
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("collector returned HTTP %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package p0d
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var traceparentRegex = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+
+func TestValidateTracing(t *testing.T) {
+	cfg := Config{Exec: Exec{Tracing: Tracing{Endpoint: "http://localhost:4318/v1/traces"}}}
+	cfg.validateTracing()
+	if cfg.Exec.Tracing.Sampling != 1 || cfg.Exec.Tracing.ServiceName != "p0d" {
+		t.Error("tracing defaults incorrect")
+	}
+	if !cfg.isTracing() {
+		t.Error("endpoint should enable tracing")
+	}
+
+	cfg = Config{}
+	cfg.validateTracing()
+	if cfg.isTracing() {
+		t.Error("tracing should be off by default")
+	}
+}
+
+func TestTraceReq(t *testing.T) {
+	p := &P0d{Config: Config{Exec: Exec{Tracing: Tracing{Sampling: 1}}}}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	ra := ReqAtmpt{}
+	p.traceReq(req, &ra)
+	tp := req.Header.Get(traceparent)
+	if !traceparentRegex.MatchString(tp) {
+		t.Errorf("traceparent header incorrect, got %v", tp)
+	}
+	if tp != "00-"+ra.TraceID+"-"+ra.SpanID+"-01" {
+		t.Error("traceparent should carry the attempt's ids")
+	}
+
+	p = &P0d{Config: Config{}}
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	ra = ReqAtmpt{}
+	p.traceReq(req, &ra)
+	if req.Header.Get(traceparent) != N || ra.TraceID != N {
+		t.Error("should not trace without sampling")
+	}
+
+	//attempts logged to -O are traced regardless of the tracing sample.
+	p = &P0d{Config: Config{Exec: Exec{Tracing: Tracing{Sampling: 0.0000001}}}}
+	req, _ = http.NewRequest("GET", "http://localhost:8080/", nil)
+	ra = ReqAtmpt{logged: true}
+	p.traceReq(req, &ra)
+	if ra.TraceID == N {
+		t.Error("should trace an attempt logged to -O")
+	}
+}
+
+func TestExportSpans(t *testing.T) {
+	seen := make(chan string, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Get(traceparent)
+		w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+
+	bodies := make(chan []byte, 10)
+	col := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer col.Close()
+
+	p := NewP0dWithValues(1, 3, svr.URL, "1.1", "", true)
+	p.Config.Exec.Tracing = Tracing{Endpoint: col.URL + "/v1/traces"}
+	p.Config.validateTracing()
+	p.initSpanExporter()
+
+	ra := p.doReqAtmptFor(0, time.Time{}, &p.Config.Req, nil, nil)
+	p.stopSpanExporter()
+
+	if tp := <-seen; !traceparentRegex.MatchString(tp) {
+		t.Errorf("server should see traceparent, got %v", tp)
+	}
+
+	var body struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan
+			}
+		}
+	}
+	select {
+	case b := <-bodies:
+		json.Unmarshal(b, &body)
+	case <-time.After(time.Second):
+		t.Fatal("collector should receive spans")
+	}
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatal("otlp payload incorrect")
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) < 2 || spans[0].TraceId != ra.TraceID || spans[0].Kind != spanKindClient {
+		t.Errorf("client span incorrect, got %v", spans)
+	}
+	for _, s := range spans[1:] {
+		if s.ParentSpanId != ra.SpanID || s.TraceId != ra.TraceID {
+			t.Errorf("phase span %v should be a child of the client span", s.Name)
+		}
+	}
+	if p.spanExporter.dropped != 0 {
+		t.Error("should export without errors")
+	}
+}
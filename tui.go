@@ -0,0 +1,387 @@
+package p0d
+
+import (
+	"fmt"
+	. "github.com/logrusorgru/aurora"
+	"golang.org/x/term"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tuiAltScreen  = "\x1b[?1049h\x1b[?25l"
+	tuiMainScreen = "\x1b[?25h\x1b[?1049l"
+	tuiHome       = "\x1b[H"
+	tuiClearLine  = "\x1b[K"
+	tuiClearBelow = "\x1b[J"
+	tuiSample     = time.Second
+	tuiHistory    = 240
+	tuiLogLines   = 5
+	tuiBarWidth   = 30
+	tuiMaxLatency = 100000
+	tuiTTY        = "/dev/tty"
+	ctrlC         = 3
+)
+
+const tuiKeysMsg = "p pause view  + more conns  - fewer conns  q stop"
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+type tuiReporter struct {
+	p      *P0d
+	out    io.Writer
+	in     *os.File
+	tty    bool
+	state  *term.State
+	keys   chan byte
+	width  int
+	paused bool
+	done   bool
+	status string
+	logs   []string
+
+	last  time.Time
+	lat   []float64
+	seen  int64
+	codes map[int]int
+	rps   []float64
+	p50   []float64
+	p99   []float64
+	conns []float64
+}
+
+func NewTUIReporter(p *P0d) Reporter {
+	return &tuiReporter{
+		p:     p,
+		out:   os.Stdout,
+		in:    os.Stdin,
+		keys:  make(chan byte, 16),
+		width: 80,
+		codes: make(map[int]int),
+	}
+}
+
+func (t *tuiReporter) OnStart(cfg Config) {
+	if w, _, e := term.GetSize(int(os.Stdout.Fd())); e == nil {
+		t.width = w
+	}
+	//our own handle on the terminal can be closed to stop reading keys afterwards, stdin can't.
+	if f, e := os.Open(tuiTTY); e == nil {
+		t.in, t.tty = f, true
+	}
+	//raw mode hands us single keys, CTRL+C included. without a terminal the view still works, the keys don't.
+	if s, e := t.makeRaw(); e == nil {
+		t.state = s
+		go t.readKeys()
+	} else if t.tty {
+		t.in.Close()
+	}
+	fmt.Fprint(t.out, tuiAltScreen)
+	if cfg.File != N {
+		t.OnLog(fmt.Sprintf("config loaded from '%s'", Yellow(cfg.File)))
+	}
+	if !cfg.Exec.SkipInetTest {
+		t.OnLog("detecting inet speed")
+	}
+	t.render(time.Now())
+	if msg := t.p.awaitInetTest(); msg != N {
+		t.OnLog(msg)
+	}
+	t.OnLog(fmt.Sprintf("starting engines: %v", Cyan(t.p.ID)))
+}
+
+func (t *tuiReporter) OnLog(msg string) {
+	msg = strings.TrimSuffix(msg, "\n")
+	if t.done {
+		log("%s", msg)
+		return
+	}
+	t.logs = append(t.logs, timefmt(msg))
+	if len(t.logs) > tuiLogLines {
+		t.logs = t.logs[len(t.logs)-tuiLogLines:]
+	}
+}
+
+func (t *tuiReporter) OnPhase(phase TimerPhase) {}
+
+func (t *tuiReporter) OnAttempt(ra ReqAtmpt) {
+	if ra.ResCode > 0 {
+		t.codes[ra.ResCode]++
+	}
+	//a reservoir keeps an even sample of the whole second, not just its start.
+	t.seen++
+	if len(t.lat) < tuiMaxLatency {
+		t.lat = append(t.lat, float64(ra.ElpsdNs))
+	} else if i := rand.Int63n(t.seen); i < tuiMaxLatency {
+		t.lat[i] = float64(ra.ElpsdNs)
+	}
+}
+
+func (t *tuiReporter) OnSnapshot(s *ReqStats) {
+	t.handleKeys()
+	now := time.Now()
+	if t.last.IsZero() {
+		t.last = now
+	} else if now.Sub(t.last) >= tuiSample {
+		t.sample(s)
+		t.last = now
+	}
+	if !t.paused {
+		t.render(now)
+	}
+}
+
+func (t *tuiReporter) OnFinish(p *P0d) {
+	fmt.Fprint(t.out, tuiMainScreen)
+	if t.state != nil {
+		t.restore()
+	}
+	//this ends readKeys, embedders keep running after us.
+	if t.tty {
+		t.in.Close()
+	}
+	t.done = true
+
+	//what's left on screen afterwards is the same as without the dashboard.
+	c := &consoleReporter{p: p}
+	c.OnSnapshot(p.ReqStats)
+	c.OnFinish(p)
+}
+
+func (t *tuiReporter) makeRaw() (*term.State, error) {
+	var s *term.State
+	e := t.control(func(fd int) error {
+		var me error
+		s, me = term.MakeRaw(fd)
+		return me
+	})
+	return s, e
+}
+
+func (t *tuiReporter) restore() {
+	t.control(func(fd int) error {
+		return term.Restore(fd, t.state)
+	})
+}
+
+func (t *tuiReporter) control(fn func(fd int) error) error {
+	//Fd() would switch the terminal back to blocking reads, then Close no longer interrupts them.
+	rc, e := t.in.SyscallConn()
+	if e != nil {
+		return e
+	}
+	var fe error
+	if e = rc.Control(func(fd uintptr) {
+		fe = fn(int(fd))
+	}); e != nil {
+		return e
+	}
+	return fe
+}
+
+func (t *tuiReporter) readKeys() {
+	b := make([]byte, 1)
+	for {
+		if n, e := t.in.Read(b); e != nil || n == 0 {
+			return
+		}
+		//stop right here, the main loop may still be waiting for the inet test. q finishes the run, CTRL+C
+		//interrupts it.
+		if b[0] == 'q' {
+			t.p.stopRun()
+		} else if b[0] == ctrlC {
+			t.p.interruptRun()
+		}
+		select {
+		case t.keys <- b[0]:
+		default:
+		}
+	}
+}
+
+func (t *tuiReporter) handleKeys() {
+	for {
+		select {
+		case k := <-t.keys:
+			switch k {
+			case 'p', ' ':
+				t.paused = !t.paused
+			case '+', '=':
+				t.resize(1)
+			case '-', '_':
+				t.resize(-1)
+			case 'q', ctrlC:
+				t.status = "stopping, draining open conns"
+			}
+			t.render(time.Now())
+		default:
+			return
+		}
+	}
+}
+
+func (t *tuiReporter) resize(dir int) {
+	max := t.p.Config.Exec.Concurrency
+	step := int(math.Max(1, float64(max/10)))
+	n := t.p.ActiveConcurrency() + dir*step
+	if n < 1 {
+		n = 1
+	} else if n > max {
+		n = max
+	}
+	if t.p.SetConcurrency(n) {
+		t.status = fmt.Sprintf("concurrency set to %s/%s", FGroup(int64(n)), FGroup(int64(max)))
+	} else {
+		t.status = "concurrency only changes in the main phase of a closed loop run without stages"
+	}
+}
+
+func (t *tuiReporter) sample(s *ReqStats) {
+	q := func(v float64) float64 {
+		if len(t.lat) == 0 {
+			return 0
+		}
+		return t.lat[int(math.Ceil(v*float64(len(t.lat))))-1]
+	}
+	sort.Float64s(t.lat)
+	t.rps = appendHistory(t.rps, float64(atomic.LoadInt64(&s.CurReqAtmptsPSec)))
+	t.p50 = appendHistory(t.p50, q(0.5))
+	t.p99 = appendHistory(t.p99, q(0.99))
+	t.conns = appendHistory(t.conns, float64(t.p.getOSOpenConns().OpenConns))
+	t.lat = t.lat[:0]
+	t.seen = 0
+}
+
+func (t *tuiReporter) render(now time.Time) {
+	s := t.p.ReqStats
+	w := t.width - 28
+	if w < 10 {
+		w = 10
+	}
+	lines := make([]string, 0)
+
+	header := fmt.Sprintf("%v %v", Cyan(vs), Gray(12, t.p.ID))
+	if t.paused {
+		header += Yellow(" [paused]").String()
+	}
+	lines = append(lines, header)
+	if !t.p.Time.Start.IsZero() {
+		lines = append(lines, t.p.bar.render(now, t.p))
+	}
+	phase := fmt.Sprintf("phase: %v open conns: %v/%v max: %v", Cyan(timerPhaseNames[t.p.Time.Phase]),
+		Cyan(FGroup(int64(t.p.getOSOpenConns().OpenConns))), Cyan(FGroup(int64(t.p.Config.Exec.Concurrency))),
+		Magenta(FGroup(int64(t.p.OS.MaxOpenConns))))
+	if t.p.Time.Stage != N {
+		phase += fmt.Sprintf(" stage: %v", Cyan(t.p.Time.Stage))
+	}
+	lines = append(lines, phase, N)
+
+	last := func(v []float64) float64 {
+		if len(v) == 0 {
+			return 0
+		}
+		return v[len(v)-1]
+	}
+	lines = append(lines,
+		fmt.Sprintf("rps   %v %v%s", Cyan(spark(t.rps, w)), Cyan(FGroup(int64(last(t.rps)))), perSecondMsg),
+		fmt.Sprintf("p50   %v %v", Cyan(spark(t.p50, w)), Cyan(fmtNsFloat(last(t.p50)))),
+		fmt.Sprintf("p99   %v %v", Magenta(spark(t.p99, w)), Magenta(fmtNsFloat(last(t.p99)))),
+		fmt.Sprintf("conns %v %v", Cyan(spark(t.conns, w)), Cyan(FGroup(int64(last(t.conns))))),
+		N)
+
+	lines = append(lines, fmt.Sprintf("HTTP req: %v", Cyan(FGroup(s.ReqAtmpts))))
+	codes := make([]int, 0, len(t.codes))
+	maxCodes := 0
+	for c, n := range t.codes {
+		codes = append(codes, c)
+		maxCodes = int(math.Max(float64(maxCodes), float64(n)))
+	}
+	sort.Ints(codes)
+	for _, c := range codes {
+		v := fmt.Sprintf("  %d %s %s (%.2f%%)", c, bar(t.codes[c], maxCodes), FGroup(int64(t.codes[c])),
+			100*float64(t.codes[c])/float64(s.ReqAtmpts))
+		if t.p.Config.Res.matchesCode(c) {
+			lines = append(lines, Cyan(v).String())
+		} else {
+			lines = append(lines, Red(v).String())
+		}
+	}
+
+	lines = append(lines, fmt.Sprintf("transport errors: %v", Cyan(FGroup(int64(s.SumErrors)))))
+	errs := make([]string, 0, len(s.ErrorTypes))
+	maxErrs := 0
+	for e, n := range s.ErrorTypes {
+		errs = append(errs, e)
+		maxErrs = int(math.Max(float64(maxErrs), float64(n)))
+	}
+	//most frequent first, the rest doesn't fit.
+	sort.Slice(errs, func(i, j int) bool {
+		return s.ErrorTypes[errs[i]] > s.ErrorTypes[errs[j]]
+	})
+	if len(errs) > tuiLogLines {
+		errs = errs[:tuiLogLines]
+	}
+	for _, e := range errs {
+		lines = append(lines, Red(fmt.Sprintf("  %s %s %s", bar(s.ErrorTypes[e], maxErrs),
+			FGroup(int64(s.ErrorTypes[e])), e)).String())
+	}
+	lines = append(lines, N)
+
+	for _, l := range t.logs {
+		lines = append(lines, strings.TrimSuffix(l, "\n"))
+	}
+	if t.status != N {
+		lines = append(lines, Yellow(t.status).String())
+	}
+	lines = append(lines, Gray(12, tuiKeysMsg).String())
+
+	b := strings.Builder{}
+	b.WriteString(tuiHome)
+	for _, l := range lines {
+		//raw mode doesn't return the carriage for us
+		b.WriteString(l + tuiClearLine + "\r\n")
+	}
+	b.WriteString(tuiClearBelow)
+	fmt.Fprint(t.out, b.String())
+}
+
+func appendHistory(h []float64, v float64) []float64 {
+	h = append(h, v)
+	if len(h) > tuiHistory {
+		h = h[len(h)-tuiHistory:]
+	}
+	return h
+}
+
+func spark(h []float64, w int) string {
+	if len(h) > w {
+		h = h[len(h)-w:]
+	}
+	max := 0.0
+	for _, v := range h {
+		max = math.Max(max, v)
+	}
+	b := strings.Builder{}
+	for _, v := range h {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparks)-1)))
+		}
+		b.WriteRune(sparks[i])
+	}
+	b.WriteString(strings.Repeat(" ", w-len(h)))
+	return b.String()
+}
+
+func bar(v int, max int) string {
+	if max == 0 {
+		return N
+	}
+	return strings.Repeat("█", int(math.Ceil(float64(v)/float64(max)*tuiBarWidth)))
+}
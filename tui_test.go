@@ -0,0 +1,112 @@
+package p0d
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sparkTest struct {
+	h    []float64
+	w    int
+	want string
+}
+
+func TestSpark(t *testing.T) {
+	tests := []sparkTest{
+		{[]float64{}, 3, "   "},
+		{[]float64{0, 0}, 2, "▁▁"},
+		{[]float64{0, 7, 14}, 4, "▁▅█ "},
+		{[]float64{1, 2, 3, 4}, 2, "▆█"},
+	}
+	for _, tt := range tests {
+		if got := spark(tt.h, tt.w); got != tt.want {
+			t.Errorf("spark %v should be %q, got %q", tt.h, tt.want, got)
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	if bar(0, 0) != N || bar(10, 10) != strings.Repeat("█", tuiBarWidth) || bar(1, 100) != "█" {
+		t.Error("bar incorrect")
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	h := make([]float64, 0)
+	for i := 0; i < tuiHistory+10; i++ {
+		h = appendHistory(h, float64(i))
+	}
+	if len(h) != tuiHistory || h[0] != 10 {
+		t.Errorf("history should keep the last %d values, got %d from %v", tuiHistory, len(h), h[0])
+	}
+}
+
+func TestTUIReporterSamplesLatencyEvenly(t *testing.T) {
+	r := NewTUIReporter(nil).(*tuiReporter)
+	n := tuiMaxLatency * 3
+	for i := 0; i < n; i++ {
+		r.OnAttempt(ReqAtmpt{ElpsdNs: time.Duration(i)})
+	}
+	late := 0
+	for _, v := range r.lat {
+		if v >= float64(tuiMaxLatency) {
+			late++
+		}
+	}
+	if len(r.lat) != tuiMaxLatency || late < tuiMaxLatency/2 {
+		t.Errorf("latency sample should cover the whole second, got %d of %d from its end", late, len(r.lat))
+	}
+}
+
+func TestTUIReporter(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "123456789")
+	}))
+	defer svr.Close()
+
+	p := newTestP0d(t, svr.URL, "")
+	out := bytes.Buffer{}
+	r := NewTUIReporter(p).(*tuiReporter)
+	r.out = &out
+	p.SetReporters(r)
+
+	//fewer conns once we're in the main phase, then stop like the q key would.
+	go func() {
+		for !p.isTimerPhase(Main) {
+			time.Sleep(time.Millisecond * 50)
+		}
+		r.keys <- '-'
+		time.Sleep(time.Millisecond * 1500)
+		r.keys <- 'q'
+		p.stopRun()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
+	defer cancel()
+	res, _ := p.Race(ctx)
+
+	if p.ActiveConcurrency() != 1 {
+		t.Errorf("concurrency should be down to 1, got %d", p.ActiveConcurrency())
+	}
+	if res.Time.Stop.Sub(res.Time.Start) > time.Second*6 {
+		t.Error("stop key should end the run early")
+	}
+	if res.Interrupted || res.ExitCode == ExitInterrupted {
+		t.Errorf("stop key should finish the run, not interrupt it, got exit code %d", res.ExitCode)
+	}
+	o := out.String()
+	if !strings.Contains(o, tuiAltScreen) || !strings.Contains(o, tuiMainScreen) {
+		t.Error("dashboard should run on the alternate screen and leave it")
+	}
+	if !strings.Contains(o, "concurrency set to 1/2") || !strings.Contains(o, "  200 ") {
+		t.Errorf("dashboard should show status and codes, got %s", o)
+	}
+	if len(r.rps) == 0 || r.rps[0] == 0 {
+		t.Errorf("dashboard should sample rps every second, got %v", r.rps)
+	}
+}